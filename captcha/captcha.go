@@ -1,17 +1,14 @@
 package captcha
 
 import (
-	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"math/rand"
 	"net/http"
-	"net/http/cookiejar"
-	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -24,12 +21,31 @@ type CaptchaResult struct {
 	Result []int  `json:"result,omitempty"`
 }
 
-func GetCaptcha(jar *cookiejar.Jar) (res string, err error) {
+// 验证码使用场景
+const (
+	loginModule  = "login"  // 登录
+	loginRand    = "sjrand" // 登录验证码的 rand 参数
+	loginReferer = "https://kyfw.12306.cn/otn/resources/login.html"
+
+	orderModule = "passenger" // 提交订单
+	orderRand   = "randp"     // 提交订单验证码的 rand 参数
+)
+
+// GetCaptcha 获取登录验证码图像
+func GetCaptcha(sess *session.Session) (res string, err error) {
+	return getCaptcha(sess, loginModule, loginRand, loginReferer)
+}
+
+// GetOrderCaptcha 获取提交订单验证码图像，referer 为行程类型对应的下单页面
+func GetOrderCaptcha(sess *session.Session, referer string) (res string, err error) {
+	return getCaptcha(sess, orderModule, orderRand, referer)
+}
+
+func getCaptcha(sess *session.Session, module, randType, referer string) (res string, err error) {
 	const (
-		url     = "https://%s/passport/captcha/captcha-image64?login_site=E&module=login&rand=sjrand&_=%f"
-		referer = "https://kyfw.12306.cn/otn/resources/login.html"
+		url = "https://%s/passport/captcha/captcha-image64?login_site=E&module=%s&rand=%s&_=%f"
 	)
-	req, _ := http.NewRequest("GET", fmt.Sprintf(url, cdn.GetCDN(), rand.Float32()), nil)
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetCDN(), module, randType, rand.Float32()), nil)
 	req.Header.Add("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -37,7 +53,7 @@ func GetCaptcha(jar *cookiejar.Jar) (res string, err error) {
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("获取验证码错误", zap.Error(err))
 
 		return "", err
@@ -60,48 +76,6 @@ func GetCaptcha(jar *cookiejar.Jar) (res string, err error) {
 	return cap.Image, nil
 }
 
-func GetCaptchaResult(jar *cookiejar.Jar, ocrURL, base64Img string) (result []int, answer string, err error) {
-	payload := url.Values{}
-	payload.Add("img", base64Img)
-
-	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", ocrURL, buf)
-	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
-
-	var (
-		body       []byte
-		statusCode int
-		t0         time.Time = time.Now()
-	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
-		logger.Error("获取验证码结果错误", zap.Error(err))
-
-		return nil, "", err
-	} else if statusCode != http.StatusOK {
-		logger.Error("获取验证码结果失败", zap.Int("statusCode", statusCode), zap.ByteString("res", body))
-
-		return nil, "", errors.New("get captcha result failure")
-	}
-
-	response := CaptchaResult{}
-	if err = json.Unmarshal(body, &response); err != nil {
-		logger.Error("解析验证码结果错误", zap.ByteString("res", body), zap.Error(err))
-
-		return nil, "", err
-	}
-
-	result = response.Result
-	answer = ConvertCaptchaResult(&response)
-
-	logger.Info("验证码识别耗时",
-		zap.Duration("耗时", time.Since(t0)),
-		zap.Ints("答案编号", result),
-		zap.String("答案坐标", answer),
-	)
-
-	return
-}
-
 func ConvertCaptchaResult(result *CaptchaResult) (ret string) {
 	/*
 		验证码选项图像编号：
@@ -121,6 +95,10 @@ func ConvertCaptchaResult(result *CaptchaResult) (ret string) {
 	}
 
 	for _, pos := range result.Result {
+		if pos < 1 || pos > len(table) {
+			continue
+		}
+
 		ret += strconv.Itoa(table[pos-1][0]+rand.Intn(60)-30) + "," +
 			strconv.Itoa(table[pos-1][1]+rand.Intn(60)-30) + ","
 	}
@@ -132,12 +110,21 @@ func ConvertCaptchaResult(result *CaptchaResult) (ret string) {
 	return
 }
 
-func VerifyCaptcha(jar *cookiejar.Jar, answer string) (pass bool, err error) {
+// VerifyCaptcha 校验登录验证码
+func VerifyCaptcha(sess *session.Session, answer string) (pass bool, err error) {
+	return verifyCaptcha(sess, answer, loginRand, loginReferer)
+}
+
+// VerifyOrderCaptcha 校验提交订单验证码，referer 为行程类型对应的下单页面
+func VerifyOrderCaptcha(sess *session.Session, answer, referer string) (pass bool, err error) {
+	return verifyCaptcha(sess, answer, orderRand, referer)
+}
+
+func verifyCaptcha(sess *session.Session, answer, randType, referer string) (pass bool, err error) {
 	const (
-		url0    = "https://%s/passport/captcha/captcha-check?answer=%s&rand=sjrand&login_site=E&_=%f"
-		referer = "https://kyfw.12306.cn/otn/resources/login.html"
+		url0 = "https://%s/passport/captcha/captcha-check?answer=%s&rand=%s&login_site=E&_=%f"
 	)
-	req, _ := http.NewRequest("GET", fmt.Sprintf(url0, cdn.GetCDN(), answer, float32(time.Now().UnixMilli())), nil)
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url0, sess.CDN.GetCDN(), answer, randType, float32(time.Now().UnixMilli())), nil)
 	req.Header.Add("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -145,7 +132,7 @@ func VerifyCaptcha(jar *cookiejar.Jar, answer string) (pass bool, err error) {
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("检查验证码错误", zap.Error(err))
 
 		return false, err
@@ -2,8 +2,11 @@ package captcha_test
 
 import (
 	"gogo12306/captcha"
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
 	"gogo12306/logger"
-	"net/http/cookiejar"
+	"gogo12306/session"
 	"testing"
 
 	"go.uber.org/zap"
@@ -17,40 +20,36 @@ func TestCaptcha(t *testing.T) {
 
 	var (
 		err       error
-		jar       *cookiejar.Jar
+		sess      *session.Session
 		base64Img string
 		pass      bool
 	)
-	if jar, err = cookiejar.New(nil); err != nil {
+	if sess, err = session.New(&config.Config{}, &config.LoginConfig{}, cdn.NewPool(), common.NewStations()); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 获取校验码图片 BASE64
-	if base64Img, err = captcha.GetCaptcha(jar); err != nil {
+	if base64Img, err = captcha.GetCaptcha(sess); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 自动识别校验码
-	var (
-		result []int
-		answer string
-	)
-	if result, answer, err = captcha.GetCaptchaResult(jar, OCRURL, base64Img); err != nil {
+	var answer string
+	if answer, err = (&captcha.HTTPSolver{URL: OCRURL}).Solve(base64Img); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 验证校验码结果
-	if pass, err = captcha.VerifyCaptcha(jar, answer); err != nil {
+	if pass, err = captcha.VerifyCaptcha(sess, answer); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	logger.Info("校验码验证结果",
 		zap.String("校验码图片信息", base64Img),
-		zap.Any("校验码 OCR 结果", result),
 		zap.String("转化后坐标点", answer),
 		zap.Bool("校验码验证是否通过", pass),
 	)
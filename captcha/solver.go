@@ -0,0 +1,235 @@
+package captcha
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogo12306/config"
+	"gogo12306/httpcli"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	SolverOCR    = "ocr"    // 自建 OCR 服务识别
+	SolverManual = "manual" // 在终端手动输入
+	SolverStub   = "stub"   // 固定答案，用于测试
+)
+
+// Solver 验证码识别器
+type Solver interface {
+	// Solve 识别 BASE64 编码的验证码图像，返回提交给 12306 的答案坐标
+	Solve(base64Img string) (answer string, err error)
+}
+
+// NewSolver 根据登录配置创建验证码识别器，未配置时使用 OCR 服务
+func NewSolver(cfg *config.LoginConfig) (solver Solver, err error) {
+	switch cfg.CaptchaSolver {
+	case "", SolverOCR:
+		if cfg.OCRUrl == "" {
+			logger.Error("使用 OCR 识别验证码需要配置 ocr_url")
+
+			return nil, errors.New("ocr_url is empty")
+		}
+
+		return &HTTPSolver{URL: cfg.OCRUrl}, nil
+
+	case SolverManual:
+		imagePath := cfg.CaptchaImagePath
+		if imagePath == "" {
+			imagePath = config.DefaultCaptchaImagePath
+		}
+
+		return &ManualSolver{ImagePath: imagePath, In: os.Stdin, Out: os.Stdout}, nil
+
+	case SolverStub:
+		return &StubSolver{Tiles: []int{1}}, nil
+
+	default:
+		logger.Error("不支持的验证码识别方式", zap.String("captcha_solver", cfg.CaptchaSolver))
+
+		return nil, errors.New("unknown captcha solver")
+	}
+}
+
+// HTTPSolver 使用自建的 OCR 服务识别验证码，自建方法参考: https://py12306-helper.pjialin.com/
+type HTTPSolver struct {
+	URL string
+}
+
+func (s *HTTPSolver) Solve(base64Img string) (answer string, err error) {
+	payload := url.Values{}
+	payload.Add("img", base64Img)
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", s.URL, buf)
+	req.Header.Add("Content-Type", "application/x-www-form-urlencoded")
+
+	var (
+		body       []byte
+		statusCode int
+		t0         time.Time = time.Now()
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, nil); err != nil {
+		logger.Error("获取验证码结果错误", zap.Error(err))
+
+		return "", err
+	} else if statusCode != http.StatusOK {
+		logger.Error("获取验证码结果失败", zap.Int("statusCode", statusCode), zap.ByteString("res", body))
+
+		return "", errors.New("get captcha result failure")
+	}
+
+	response := CaptchaResult{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		logger.Error("解析验证码结果错误", zap.ByteString("res", body), zap.Error(err))
+
+		return "", err
+	}
+
+	answer = ConvertCaptchaResult(&response)
+
+	logger.Info("验证码识别耗时",
+		zap.Duration("耗时", time.Since(t0)),
+		zap.Ints("答案编号", response.Result),
+		zap.String("答案坐标", answer),
+	)
+
+	if answer == "" {
+		logger.Error("验证码识别失败", zap.String("msg", response.Msg))
+
+		return "", errors.New("captcha not recognized")
+	}
+
+	return
+}
+
+// ManualSolver 把验证码图像保存到文件，由用户查看后在终端输入答案编号
+type ManualSolver struct {
+	ImagePath string
+	In        io.Reader
+	Out       io.Writer
+}
+
+func (s *ManualSolver) Solve(base64Img string) (answer string, err error) {
+	var img []byte
+	if img, err = base64.StdEncoding.DecodeString(base64Img); err != nil {
+		logger.Error("解码验证码图像错误", zap.Error(err))
+
+		return
+	}
+
+	if err = ioutil.WriteFile(s.ImagePath, img, 0644); err != nil {
+		logger.Error("保存验证码图像错误", zap.String("path", s.ImagePath), zap.Error(err))
+
+		return
+	}
+
+	fmt.Fprintf(s.Out, "\n验证码图像已保存到 %s，图片编号如下:\n", s.ImagePath)
+	fmt.Fprintln(s.Out, "*****************")
+	fmt.Fprintln(s.Out, "| 1 | 2 | 3 | 4 |")
+	fmt.Fprintln(s.Out, "*****************")
+	fmt.Fprintln(s.Out, "| 5 | 6 | 7 | 8 |")
+	fmt.Fprintln(s.Out, "*****************")
+	fmt.Fprint(s.Out, "请输入所有符合要求的图片编号，以逗号分隔（如 1,6）: ")
+
+	var line string
+	if line, err = bufio.NewReader(s.In).ReadString('\n'); err != nil && line == "" {
+		logger.Error("读取验证码答案错误", zap.Error(err))
+
+		return
+	}
+
+	result := CaptchaResult{}
+	for _, field := range strings.FieldsFunc(line, func(r rune) bool {
+		return r == ',' || r == '，' || r == ' ' || r == '\r' || r == '\n'
+	}) {
+		var tile int
+		if tile, err = strconv.Atoi(field); err != nil || tile < 1 || tile > 8 {
+			logger.Error("验证码答案编号错误", zap.String("编号", field))
+
+			return "", errors.New("invalid captcha tile")
+		}
+
+		result.Result = append(result.Result, tile)
+	}
+
+	if len(result.Result) == 0 {
+		return "", errors.New("captcha answer is empty")
+	}
+
+	return ConvertCaptchaResult(&result), nil
+}
+
+// StubSolver 总是返回固定的图片编号，用于测试
+type StubSolver struct {
+	Tiles []int
+}
+
+func (s *StubSolver) Solve(base64Img string) (answer string, err error) {
+	return ConvertCaptchaResult(&CaptchaResult{Result: s.Tiles}), nil
+}
+
+// 验证码识别或校验失败时默认的最多尝试次数
+const defaultRetries = 3
+
+// SolveLogin 获取并识别登录验证码，校验通过后返回答案坐标
+func SolveLogin(sess *session.Session, solver Solver) (answer string, err error) {
+	return solve(sess, solver, GetCaptcha, VerifyCaptcha)
+}
+
+// SolveOrder 获取并识别提交订单验证码，校验通过后返回答案坐标，referer 为行程类型对应的下单页面
+func SolveOrder(sess *session.Session, solver Solver, referer string) (answer string, err error) {
+	return solve(sess, solver,
+		func(sess *session.Session) (string, error) { return GetOrderCaptcha(sess, referer) },
+		func(sess *session.Session, answer string) (bool, error) {
+			return VerifyOrderCaptcha(sess, answer, referer)
+		})
+}
+
+// solve 识别或校验失败时重新获取验证码，最多尝试 captcha_retries 次
+func solve(sess *session.Session, solver Solver,
+	get func(*session.Session) (string, error),
+	verify func(*session.Session, string) (bool, error)) (answer string, err error) {
+	retries := sess.Login.CaptchaRetries
+	if retries <= 0 {
+		retries = defaultRetries
+	}
+
+	for i := 1; i <= retries; i++ {
+		var base64Img string
+		if base64Img, err = get(sess); err != nil {
+			continue
+		}
+
+		if answer, err = solver.Solve(base64Img); err != nil {
+			continue
+		}
+
+		var pass bool
+		if pass, err = verify(sess, answer); err != nil {
+			continue
+		} else if pass {
+			return answer, nil
+		}
+
+		logger.Warn("验证码校验不通过，重新获取验证码", zap.Int("第几次", i), zap.Int("最多尝试次数", retries))
+		err = errors.New("verify captcha failure")
+	}
+
+	logger.Error("验证码已超过最多尝试次数", zap.Int("最多尝试次数", retries), zap.Error(err))
+	return "", err
+}
@@ -0,0 +1,55 @@
+package captcha_test
+
+import (
+	"bytes"
+	"encoding/base64"
+	"gogo12306/captcha"
+	"gogo12306/config"
+	"gogo12306/logger"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestManualSolver(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	var out bytes.Buffer
+	solver := &captcha.ManualSolver{
+		ImagePath: filepath.Join(t.TempDir(), "captcha.jpg"),
+		In:        strings.NewReader("1，6\n"),
+		Out:       &out,
+	}
+
+	answer, err := solver.Solve(base64.StdEncoding.EncodeToString([]byte("mock")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(strings.Split(answer, ",")); n != 4 {
+		t.Errorf("answer %s has %d coordinates, want 4", answer, n)
+	}
+
+	solver.In = strings.NewReader("9\n")
+	if _, err = solver.Solve(base64.StdEncoding.EncodeToString([]byte("mock"))); err == nil {
+		t.Error("tile 9 should be rejected")
+	}
+}
+
+func TestNewSolver(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	if _, err := captcha.NewSolver(&config.LoginConfig{}); err == nil {
+		t.Error("ocr solver without ocr_url should fail")
+	}
+
+	if solver, err := captcha.NewSolver(&config.LoginConfig{CaptchaSolver: captcha.SolverStub}); err != nil {
+		t.Error(err)
+	} else if answer, _ := solver.Solve(""); answer == "" {
+		t.Error("stub solver returned empty answer")
+	}
+
+	if _, err := captcha.NewSolver(&config.LoginConfig{CaptchaSolver: "unknown"}); err == nil {
+		t.Error("unknown solver should fail")
+	}
+}
@@ -9,16 +9,35 @@ import (
 	"go.uber.org/zap"
 )
 
-var cdns []string
+// Pool 可用的 CDN 列表
+type Pool struct {
+	cdns []string
 
-func init() {
-	cdns = make([]string, 0)
+	// 替代官方网站的地址，用于连接本地模拟服务器
+	endpoint    string
+	wwwEndpoint string
 }
 
-func LoadCDN(goodCDNPath string) (err error) {
+func NewPool() *Pool {
+	return &Pool{
+		cdns: make([]string, 0),
+	}
+}
+
+// SetEndpoint 设置 kyfw.12306.cn 和 www.12306.cn 的替代地址，设置后将不再使用 CDN
+func (p *Pool) SetEndpoint(host, wwwHost string) {
+	p.endpoint = host
+	p.wwwEndpoint = wwwHost
+
+	if p.endpoint != "" {
+		logger.Info("使用自定义服务器地址", zap.String("host", p.endpoint), zap.String("wwwHost", p.wwwEndpoint))
+	}
+}
+
+func (p *Pool) LoadCDN(goodCDNPath string) (err error) {
 	var fCDN *os.File
 	if fCDN, err = os.Open(goodCDNPath); err != nil {
-		logger.Warn("打开可用 CDN 文件失败，建议先用 -c 参数启动本程序筛选可用 CDN 列表，当前默认使用 kyfw.12306.cn", zap.String("cdnPath", goodCDNPath), zap.Error(err))
+		logger.Warn("打开可用 CDN 文件失败，建议先执行 gogo12306 cdn filter 筛选可用 CDN 列表，当前默认使用 kyfw.12306.cn", zap.String("cdnPath", goodCDNPath), zap.Error(err))
 		return nil
 	}
 
@@ -27,30 +46,70 @@ func LoadCDN(goodCDNPath string) (err error) {
 
 	for scanner.Scan() {
 		ip := scanner.Text()
-		cdns = append(cdns, ip)
+		p.cdns = append(p.cdns, ip)
 	}
 
 	fCDN.Close()
 
-	logger.Info("可用 CDN 数量", zap.Int("count", len(cdns)))
+	logger.Info("可用 CDN 数量", zap.Int("count", len(p.cdns)))
 	return nil
 }
 
-func GetCDN0() string {
+func (p *Pool) GetCDN0() string {
+	if p.endpoint != "" {
+		return p.endpoint
+	}
+
 	return "kyfw.12306.cn"
 }
 
-func GetCDN() string {
-	if len(cdns) == 0 {
+func (p *Pool) GetWWW() string {
+	if p.wwwEndpoint != "" {
+		return p.wwwEndpoint
+	}
+
+	return "www.12306.cn"
+}
+
+// GetSearch 车次搜索 search.12306.cn 的地址，设置了自定义服务器地址时使用该地址
+func (p *Pool) GetSearch() string {
+	if p.endpoint != "" {
+		return p.endpoint
+	}
+
+	return "search.12306.cn"
+}
+
+func (p *Pool) GetCDN() string {
+	if p.endpoint != "" {
+		return p.endpoint
+	}
+
+	if len(p.cdns) == 0 {
 		return "kyfw.12306.cn"
 	}
 
-	// return cdns[rand.Intn(len(cdns)*10000)/10000]
+	// return p.cdns[rand.Intn(len(p.cdns)*10000)/10000]
+
+	n := len(p.cdns)
+	if n > 10 {
+		n = 10
+	}
+
+	return p.cdns[rand.Intn(n*10000)/10000]
+}
+
+// Hosts 所有可能被访问的 kyfw.12306.cn 地址（主站、自定义地址、CDN）
+func (p *Pool) Hosts() (hosts []string) {
+	hosts = append(hosts, "kyfw.12306.cn")
+	if p.endpoint != "" {
+		return append(hosts, p.endpoint)
+	}
 
-	n := len(cdns)
+	n := len(p.cdns)
 	if n > 10 {
 		n = 10
 	}
 
-	return cdns[rand.Intn(n*10000)/10000]
+	return append(hosts, p.cdns[:n]...)
 }
@@ -0,0 +1,103 @@
+package clock
+
+import (
+	"gogo12306/logger"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Shanghai 12306 使用的北京时间，系统没有时区数据时使用固定的 UTC+8（中国没有夏令时）
+var Shanghai = loadShanghai()
+
+func loadShanghai() *time.Location {
+	if loc, err := time.LoadLocation("Asia/Shanghai"); err == nil {
+		return loc
+	}
+
+	return time.FixedZone("CST", 8*60*60)
+}
+
+// 服务器时间与本地时间的差值（服务器时间 - 本地时间）的范围
+// 每次得到服务器时间都会缩小这个范围，取中间值作为时间差
+var (
+	mu       sync.RWMutex
+	synced   bool
+	minDelta time.Duration
+	maxDelta time.Duration
+)
+
+// Observe 记录一次服务器时间，t0、t1 为本地发出请求和收到响应的时间，
+// precision 为服务器时间的精度，如 HTTP Date 头只精确到秒，login/conf 的 now 精确到毫秒
+func Observe(server time.Time, precision time.Duration, t0, t1 time.Time) {
+	// 服务器生成时间的时刻在 t0 ~ t1 之间，真实的服务器时间在 server ~ server + precision 之间
+	lo := server.Sub(t1)
+	hi := server.Add(precision).Sub(t0)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if synced && lo <= maxDelta && hi >= minDelta {
+		// 与之前的范围有交集，取交集
+		if lo > minDelta {
+			minDelta = lo
+		}
+
+		if hi < maxDelta {
+			maxDelta = hi
+		}
+
+		return
+	}
+
+	if synced {
+		// 没有交集，说明本地时钟被调整过，重新开始
+		logger.Warn("本地时间发生跳变，重新同步服务器时间",
+			zap.Duration("原时间差", (minDelta+maxDelta)/2),
+			zap.Duration("新时间差", (lo+hi)/2),
+		)
+	}
+
+	synced = true
+	minDelta, maxDelta = lo, hi
+}
+
+// Offset 服务器时间与本地时间的差值，未同步时为 0
+func Offset() time.Duration {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	if !synced {
+		return 0
+	}
+
+	return (minDelta + maxDelta) / 2
+}
+
+// Synced 是否已同步服务器时间，precision 为时间差的误差范围
+func Synced() (ok bool, precision time.Duration) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	return synced, (maxDelta - minDelta) / 2
+}
+
+// Now 校正后的服务器时间（北京时间）
+func Now() time.Time {
+	return time.Now().Add(Offset()).In(Shanghai)
+}
+
+// Until 距离服务器时间 t 还有多久
+func Until(t time.Time) time.Duration {
+	return t.Sub(Now())
+}
+
+// Reset 清除同步结果，测试用
+func Reset() {
+	mu.Lock()
+	defer mu.Unlock()
+
+	synced = false
+	minDelta, maxDelta = 0, 0
+}
@@ -0,0 +1,55 @@
+package clock_test
+
+import (
+	"gogo12306/clock"
+	"gogo12306/logger"
+	"testing"
+	"time"
+)
+
+func TestObserve(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	clock.Reset()
+	if ok, _ := clock.Synced(); ok || clock.Offset() != 0 {
+		t.Fatal("should not be synced")
+	}
+
+	// 服务器快 5 秒，HTTP Date 只精确到秒
+	t0 := time.Date(2022, 1, 1, 0, 0, 0, 300e6, time.UTC)
+	t1 := t0.Add(time.Millisecond * 100)
+	clock.Observe(time.Date(2022, 1, 1, 0, 0, 5, 0, time.UTC), time.Second, t0, t1)
+
+	if ok, precision := clock.Synced(); !ok || precision > time.Second {
+		t.Errorf("synced: %v, precision: %s", ok, precision)
+	}
+
+	// 毫秒精度的服务器时间缩小误差范围
+	t0 = t0.Add(time.Minute)
+	t1 = t0.Add(time.Millisecond * 40)
+	clock.Observe(t0.Add(time.Second*5+time.Millisecond*20), time.Millisecond, t0, t1)
+
+	offset := clock.Offset()
+	if offset < time.Second*5-time.Millisecond*30 || offset > time.Second*5+time.Millisecond*30 {
+		t.Errorf("offset: %s", offset)
+	}
+
+	if _, precision := clock.Synced(); precision > time.Millisecond*25 {
+		t.Errorf("precision: %s", precision)
+	}
+
+	if now := clock.Now(); now.Location() != clock.Shanghai {
+		t.Errorf("location: %s", now.Location())
+	}
+
+	// 本地时间跳变后重新同步
+	t0 = t0.Add(time.Hour)
+	t1 = t0.Add(time.Millisecond * 10)
+	clock.Observe(t0.Add(-time.Hour), time.Millisecond, t0, t1)
+
+	if offset = clock.Offset(); offset > -time.Hour+time.Second || offset < -time.Hour-time.Second {
+		t.Errorf("offset after jump: %s", offset)
+	}
+
+	clock.Reset()
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/grabber"
+	"gogo12306/logger"
+	"gogo12306/login"
+	"gogo12306/mock"
+	"gogo12306/notifier"
+	"gogo12306/order"
+	"gogo12306/session"
+	"gogo12306/ticket"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// waitSignal 等待退出信号，收到 SIGHUP 时调用 onHUP（为 nil 时同样退出）
+func waitSignal(onHUP func()) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
+	for sig := range c {
+		if sig == syscall.SIGHUP && onHUP != nil {
+			onHUP()
+			continue
+		}
+
+		return
+	}
+}
+
+// newPool 按配置创建 CDN 列表
+func newPool(cfg *config.Config) (pool *cdn.Pool, err error) {
+	pool = cdn.NewPool()
+	pool.SetEndpoint(cfg.Server.Host, cfg.Server.WWWHost)
+	err = pool.LoadCDN(cfg.CDN.GoodCDNPath)
+
+	return
+}
+
+// openQuerySession 创建不登录的会话，只用于查询余票和站点，withLeftTicketURL 为 false 时不获取余票查询地址
+func openQuerySession(cfg *config.Config, withLeftTicketURL bool) (sess *session.Session, err error) {
+	var pool *cdn.Pool
+	if pool, err = newPool(cfg); err != nil {
+		return
+	}
+
+	if sess, err = session.New(cfg, &cfg.Login, pool, common.NewStations()); err != nil {
+		return
+	}
+
+	if err = ticket.InitStations(sess); err != nil {
+		return
+	}
+
+	if withLeftTicketURL {
+		err = ticket.InitLeftTickerURL(sess)
+	}
+
+	return
+}
+
+// openAccountSession 解析 -account 参数，创建该账号的会话并登录
+func openAccountSession(cfg *config.Config, name string, args []string) (sess *session.Session, err error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	account := fs.String("account", "", "账号名，默认为第一个账号")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	names, logins := cfg.AccountLogins()
+	if *account == "" {
+		*account = names[0]
+	}
+
+	loginCfg := logins[*account]
+	if loginCfg == nil {
+		return nil, fmt.Errorf("账号 %q 不存在", *account)
+	}
+
+	if !grabber.CanLogin(loginCfg) {
+		return nil, fmt.Errorf("账号 %q 没有配置用户名密码或扫码登录", *account)
+	}
+
+	var pool *cdn.Pool
+	if pool, err = newPool(cfg); err != nil {
+		return
+	}
+
+	return grabber.OpenSession(cfg, *account, loginCfg, pool, common.NewStations())
+}
+
+func runCDNFilter(cfgPath string, cfg *config.Config, args []string) (err error) {
+	logger.Info("筛选延时在 300ms 内的可用 CDN")
+
+	cdn.FilterCDN(cfg.CDN.CDNPath, cfg.CDN.GoodCDNPath)
+
+	return
+}
+
+func runGrab(cfgPath string, cfg *config.Config, args []string) (err error) {
+	logger.Info("开始抢票")
+
+	common.CheckOperationPeriod()
+
+	g := grabber.New(cfgPath, cfg)
+	if err = g.Start(); err != nil {
+		return
+	}
+
+	// 配置文件修改后自动重新加载
+	done := make(chan struct{})
+	defer close(done)
+	g.Watch(time.Second*2, done)
+
+	waitSignal(func() {
+		logger.Info("收到 SIGHUP 信号，重新加载配置", zap.String("path", cfgPath))
+
+		g.Reload()
+	})
+
+	g.Stop()
+
+	return
+}
+
+func runQuery(cfgPath string, cfg *config.Config, args []string) (err error) {
+	fs := flag.NewFlagSet("query", flag.ContinueOnError)
+	trainTypes := fs.String("type", "", "车次类型，如 GD 只显示高铁和动车，可选 G/D/C/K/Z/T/L/S/Y")
+	depart := fs.String("depart", "", "出发时间范围，如 08:00-12:00")
+	arrive := fs.String("arrive", "", "到达时间范围，如 14:00-18:00")
+	seats := fs.String("seats", "", "只显示这些座席有余票的车次，多个座席用逗号分隔，如 二等座,一等座")
+	format := fs.String("format", ticket.FormatTable, "输出格式：table、json 或 csv")
+	student := fs.Bool("student", false, "查询学生票")
+	price := fs.Bool("price", false, "查询并显示各座席的票价")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	args = fs.Args()
+	if len(args) != 3 {
+		return errors.New("用法: query [-type 车次类型] [-depart 出发时间范围] [-arrive 到达时间范围] [-seats 座席] [-format 输出格式] [-student] [-price] <出发站> <到达站> <出发日期>")
+	}
+
+	switch *format {
+	case ticket.FormatTable, ticket.FormatJSON, ticket.FormatCSV:
+	default:
+		return fmt.Errorf("未知的输出格式 %q，可选 table、json、csv", *format)
+	}
+
+	var seatNames []string
+	if *seats != "" {
+		seatNames = strings.Split(*seats, ",")
+	}
+
+	var filter *ticket.QueryFilter
+	if filter, err = ticket.NewQueryFilter(*trainTypes, *depart, *arrive, seatNames); err != nil {
+		return
+	}
+
+	from, to, startDate := args[0], args[1], args[2]
+	if _, err = time.Parse("2006-01-02", startDate); err != nil {
+		return fmt.Errorf("出发日期 %q 格式错误，应为 YYYY-MM-DD", startDate)
+	}
+
+	var sess *session.Session
+	if sess, err = openQuerySession(cfg, true); err != nil {
+		return
+	}
+	defer sess.Close()
+
+	var fromStations, toStations []*common.StationInfo
+	if fromStations, err = sess.Stations.Resolve(from); err != nil {
+		return fmt.Errorf("出发站错误: %w", err)
+	}
+
+	if toStations, err = sess.Stations.Resolve(to); err != nil {
+		return fmt.Errorf("到达站错误: %w", err)
+	}
+
+	var infos []*common.LeftTicketInfo
+	if infos, err = ticket.QueryStationsLeftTickets(sess, fromStations, toStations, startDate, *student); err != nil {
+		return
+	}
+
+	infos = ticket.FilterLeftTickets(infos, filter)
+	if *price {
+		ticket.AttachTicketPrices(sess, infos, startDate)
+	}
+
+	return ticket.WriteLeftTickets(os.Stdout, *format, common.StationNames(fromStations), common.StationNames(toStations), startDate, infos, nil)
+}
+
+func runTransfer(cfgPath string, cfg *config.Config, args []string) (err error) {
+	fs := flag.NewFlagSet("transfer", flag.ContinueOnError)
+	hubs := fs.String("hubs", "", "中转站，多个中转站用逗号分隔，可以是站点或城市，如 武汉市,长沙南，默认使用常见的中转城市")
+	maxHubs := fs.Int("max-hubs", ticket.DefaultTransferMaxHubs, "最多查询的中转站数量，0 为不限")
+	minWait := fs.Int("min", int(ticket.DefaultTransferMinWait/time.Minute), "最短换乘时间，单位: 分钟")
+	maxWait := fs.Int("max", int(ticket.DefaultTransferMaxWait/time.Minute), "最长换乘时间，单位: 分钟")
+	seats := fs.String("seats", "", "只显示两程在这些座席都有余票的方案，多个座席用逗号分隔，如 二等座,一等座")
+	limit := fs.Int("limit", 20, "最多显示的方案数量，0 为不限")
+	student := fs.Bool("student", false, "查询学生票")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	args = fs.Args()
+	if len(args) != 3 {
+		return errors.New("用法: transfer [-hubs 中转站] [-max-hubs 中转站数量] [-min 最短换乘时间] [-max 最长换乘时间] [-seats 座席] [-limit 方案数量] [-student] <出发站> <到达站> <出发日期>")
+	}
+
+	if *minWait < 0 || *maxWait < *minWait {
+		return fmt.Errorf("换乘时间范围 %d ~ %d 分钟错误", *minWait, *maxWait)
+	}
+
+	var filter *ticket.QueryFilter
+	if *seats != "" {
+		if filter, err = ticket.NewQueryFilter("", "", "", strings.Split(*seats, ",")); err != nil {
+			return
+		}
+	} else {
+		filter = &ticket.QueryFilter{}
+	}
+
+	from, to, startDate := args[0], args[1], args[2]
+	if _, err = time.Parse("2006-01-02", startDate); err != nil {
+		return fmt.Errorf("出发日期 %q 格式错误，应为 YYYY-MM-DD", startDate)
+	}
+
+	var sess *session.Session
+	if sess, err = openQuerySession(cfg, true); err != nil {
+		return
+	}
+	defer sess.Close()
+
+	var fromStations, toStations []*common.StationInfo
+	if fromStations, err = sess.Stations.Resolve(from); err != nil {
+		return fmt.Errorf("出发站错误: %w", err)
+	}
+
+	if toStations, err = sess.Stations.Resolve(to); err != nil {
+		return fmt.Errorf("到达站错误: %w", err)
+	}
+
+	var hubStations [][]*common.StationInfo
+	if *hubs != "" {
+		for _, hub := range strings.Split(*hubs, ",") {
+			var stations []*common.StationInfo
+			if stations, err = sess.Stations.Resolve(hub); err != nil {
+				return fmt.Errorf("中转站错误: %w", err)
+			}
+
+			hubStations = append(hubStations, stations)
+		}
+	}
+
+	var plans []*ticket.TransferPlan
+	if plans, err = ticket.SearchTransfers(sess, fromStations, toStations, hubStations, *maxHubs, startDate,
+		time.Duration(*minWait)*time.Minute, time.Duration(*maxWait)*time.Minute, *student); err != nil {
+		return
+	}
+
+	plans = ticket.FilterTransferPlans(plans, filter.SeatIndices)
+	if *limit > 0 && len(plans) > *limit {
+		plans = plans[:*limit]
+	}
+
+	ticket.FprintTransferPlans(os.Stdout, common.StationNames(fromStations), common.StationNames(toStations), startDate, plans)
+
+	return
+}
+
+func runTrain(cfgPath string, cfg *config.Config, args []string) (err error) {
+	if len(args) != 2 {
+		return errors.New("用法: train <车次> <始发日期>")
+	}
+
+	trainCode, startDate := strings.ToUpper(args[0]), args[1]
+	if _, err = time.Parse("2006-01-02", startDate); err != nil {
+		return fmt.Errorf("始发日期 %q 格式错误，应为 YYYY-MM-DD", startDate)
+	}
+
+	var sess *session.Session
+	if sess, err = openQuerySession(cfg, false); err != nil {
+		return
+	}
+	defer sess.Close()
+
+	var trainNo string
+	if trainNo, err = ticket.SearchTrainNo(sess, trainCode, startDate); err != nil {
+		return
+	}
+
+	var stops common.TrainStops
+	if stops, err = ticket.QueryTrainStops(sess, trainNo, "", "", startDate); err != nil {
+		return
+	}
+
+	ticket.FprintTrainStops(os.Stdout, trainCode, startDate, stops)
+
+	return
+}
+
+func runPassengers(cfgPath string, cfg *config.Config, args []string) (err error) {
+	// 登录后会获取并打印联系人列表
+	var sess *session.Session
+	if sess, err = openAccountSession(cfg, "passengers", args); err != nil {
+		return
+	}
+
+	login.SaveSession(sess)
+	sess.Close()
+
+	return
+}
+
+func runOrders(cfgPath string, cfg *config.Config, args []string) (err error) {
+	var sess *session.Session
+	if sess, err = openAccountSession(cfg, "orders", args); err != nil {
+		return
+	}
+	defer func() {
+		login.SaveSession(sess)
+		sess.Close()
+	}()
+
+	var orders []*order.OrderInfo
+	if orders, err = order.QueryMyOrderNoComplete(sess); err != nil {
+		return
+	}
+
+	fmt.Printf("未完成订单（%d）:\n", len(orders))
+	for _, o := range orders {
+		fmt.Print(o.String())
+	}
+
+	if orders, err = order.QueryMyOrder(sess); err != nil {
+		return
+	}
+
+	fmt.Printf("未出行订单（%d）:\n", len(orders))
+	for _, o := range orders {
+		fmt.Print(o.String())
+	}
+
+	return
+}
+
+func runNotifyTest(cfgPath string, cfg *config.Config, args []string) (err error) {
+	logger.Info("发送测试消息")
+
+	if err = notifier.Broadcast(&cfg.Notifier, "gogo12306 消息通知测试"); err != nil {
+		return
+	}
+
+	fmt.Println("测试消息已发送")
+
+	return
+}
+
+func runStationsSearch(cfgPath string, cfg *config.Config, args []string) (err error) {
+	if len(args) != 1 {
+		return errors.New("用法: stations search <关键字>")
+	}
+
+	var sess *session.Session
+	if sess, err = openQuerySession(cfg, false); err != nil {
+		return
+	}
+	defer sess.Close()
+
+	stations := sess.Stations.Search(args[0])
+	fmt.Println("站点名\t电报码\t拼音码\t拼音")
+	for _, station := range stations {
+		fmt.Printf("%s\t%s\t%s\t%s\n", station.StationName, station.TelegramCode, station.PYCode, station.PinYin)
+	}
+
+	fmt.Printf("共 %d 个站点\n", len(stations))
+
+	return
+}
+
+func runStationsUpdate(cfgPath string, cfg *config.Config, args []string) (err error) {
+	fs := flag.NewFlagSet("stations update", flag.ContinueOnError)
+	output := fs.String("o", cfg.Stations.CachePath, "保存路径，默认为配置中的缓存文件路径")
+	if err = fs.Parse(args); err != nil {
+		return
+	}
+
+	if *output == "" {
+		return errors.New("没有配置站点列表缓存文件路径，请使用 -o 指定保存路径")
+	}
+
+	var pool *cdn.Pool
+	if pool, err = newPool(cfg); err != nil {
+		return
+	}
+
+	var sess *session.Session
+	if sess, err = session.New(cfg, &cfg.Login, pool, common.NewStations()); err != nil {
+		return
+	}
+	defer sess.Close()
+
+	var stations *common.Stations
+	if stations, err = ticket.FetchStations(sess); err != nil {
+		return
+	}
+
+	if err = ticket.SaveStationsCache(*output, stations); err != nil {
+		return
+	}
+
+	fmt.Printf("已保存 %d 个站点到 %s\n", stations.Len(), *output)
+
+	return
+}
+
+func runValidate(cfgPath string, cfg *config.Config, args []string) (err error) {
+	logger.Info("检查配置文件", zap.String("path", cfgPath))
+
+	problems := grabber.Validate(cfg)
+	if len(problems) == 0 {
+		fmt.Println("配置检查通过")
+		return
+	}
+
+	fmt.Printf("配置文件 %s 共有 %d 个问题:\n", cfgPath, len(problems))
+	for _, p := range problems {
+		fmt.Println(p.String())
+	}
+
+	return errors.New("配置检查不通过")
+}
+
+func runMock(cfgPath string, cfg *config.Config, args []string) (err error) {
+	logger.Info("启动本地模拟 12306 服务器")
+
+	srv, err := mock.Start(cfg.Server.MockAddr, cfg.Server.MockFixtures)
+	if err != nil {
+		return
+	}
+	defer srv.Close()
+
+	waitSignal(nil)
+
+	return
+}
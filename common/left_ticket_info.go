@@ -1,35 +1,53 @@
 package common
 
 type LeftTicketInfo struct {
-	SecretStr        string // 下单用的密钥
-	CanOrder         bool   // 是否接受预订
-	TrainCode        string // 车次
-	TrainNumber      string // 列车代号，订票排队用
-	LeftTicketStr    string // 余票密钥串，订票排队用
-	CandidateFlag    bool   // 是否可以候补
-	CanWebBuy        bool   // 是否可以网上购买车票
-	Start            string // 始发站
-	End              string // 终到站
-	From             string // 出发站
-	To               string // 到达站
-	StartTime        string // 出发时间
-	ArriveTime       string // 到达时间
-	Duration         string // 历时
-	ShangWuZuo       string // 商务座
-	TeDengZuo        string // 特等座
-	YiDengZuo        string // 一等座
-	ErDengZuo        string // 二等座/二等包座
-	GaoJiRuanWo      string // 高级软卧
-	RuanWo           string // 软卧/一等卧
-	DongWo           string // 动卧
-	YingWo           string // 硬卧/二等卧
-	RuanZuo          string // 软座
-	YingZuo          string // 硬座
-	WuZuo            string // 无座
-	QiTa             string // 其他
-	LeftTicketsCount []int  // 各类型座位的剩余票数
+	SecretStr         string    // 下单用的密钥
+	CanOrder          bool      // 是否接受预订
+	TrainCode         string    // 车次
+	TrainNumber       string    // 列车代号，订票排队用
+	LeftTicketStr     string    // 余票密钥串，订票排队用
+	CandidateFlag     bool      // 是否可以候补
+	CanWebBuy         bool      // 是否可以网上购买车票
+	Start             string    // 始发站
+	End               string    // 终到站
+	From              string    // 出发站
+	To                string    // 到达站
+	FromTelegramCode  string    // 出发站电报码
+	ToTelegramCode    string    // 到达站电报码
+	StartTelegramCode string    // 始发站电报码
+	EndTelegramCode   string    // 终到站电报码
+	StartTrainDate    string    // 列车从始发站出发的日期，YYYY-MM-DD
+	FromStationNo     string    // 出发站的站序，查询票价用
+	ToStationNo       string    // 到达站的站序，查询票价用
+	SeatTypes         string    // 车次的座席类型，查询票价用
+	StartTime         string    // 出发时间
+	ArriveTime        string    // 到达时间
+	Duration          string    // 历时
+	ShangWuZuo        string    // 商务座
+	TeDengZuo         string    // 特等座
+	YiDengZuo         string    // 一等座
+	ErDengZuo         string    // 二等座/二等包座
+	GaoJiRuanWo       string    // 高级软卧
+	RuanWo            string    // 软卧/一等卧
+	DongWo            string    // 动卧
+	YingWo            string    // 硬卧/二等卧
+	RuanZuo           string    // 软座
+	YingZuo           string    // 硬座
+	WuZuo             string    // 无座
+	QiTa              string    // 其他
+	LeftTicketsCount  []int     // 各类型座位的剩余票数
+	Prices            []float64 // 各类型座位的票价，单位: 元，顺序与 LeftTicketsCount 一致，0 为未知，查询票价后才有
 }
 
 func (l *LeftTicketInfo) CanCandidate() bool {
 	return l.CanOrder && !l.CanWebBuy && l.CandidateFlag
 }
+
+// Price 座席的票价，未查询票价或没有该座席时为 0
+func (l *LeftTicketInfo) Price(seatIndex int) float64 {
+	if seatIndex < 0 || seatIndex >= len(l.Prices) {
+		return 0
+	}
+
+	return l.Prices[seatIndex]
+}
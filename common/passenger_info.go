@@ -7,6 +7,54 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+// 车票类型，与乘客类型的取值一致
+const (
+	TicketTypeAdult    = 1 // 成人票
+	TicketTypeChild    = 2 // 儿童票
+	TicketTypeStudent  = 3 // 学生票
+	TicketTypeDisabled = 4 // 残军票
+)
+
+// ParseTicketType 解析配置中的车票类型，可以是 adult/child/student/disabled-military 或 成人票/儿童票/学生票/残军票，留空为成人票
+func ParseTicketType(name string) (ticketType int, err error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "adult", "成人", "成人票":
+		return TicketTypeAdult, nil
+
+	case "child", "儿童", "儿童票":
+		return TicketTypeChild, nil
+
+	case "student", "学生", "学生票":
+		return TicketTypeStudent, nil
+
+	case "disabled-military", "disabled", "残军", "残军票":
+		return TicketTypeDisabled, nil
+
+	default:
+		return 0, fmt.Errorf("未知的车票类型 %q，可选 adult、child、student、disabled-military", name)
+	}
+}
+
+// TicketTypeName 车票类型的中文名称
+func TicketTypeName(ticketType int) string {
+	switch ticketType {
+	case TicketTypeAdult:
+		return "成人票"
+
+	case TicketTypeChild:
+		return "儿童票"
+
+	case TicketTypeStudent:
+		return "学生票"
+
+	case TicketTypeDisabled:
+		return "残军票"
+
+	default:
+		return fmt.Sprintf("未知车票类型 %d", ticketType)
+	}
+}
+
 type PassengerInfo struct {
 	PassengerName string `json:"passenger_name"`         // 乘客姓名
 	PassengerType int    `json:"passenger_type,string"`  // 乘客类型：1 - 成人票，2 - 儿童票，3 - 学生票，4 - 残军票
@@ -37,15 +85,20 @@ func (p PassengerInfos) MarshalLogArray(arr zapcore.ArrayEncoder) (err error) {
 
 type PassengerTicketInfo struct {
 	PassengerInfo
-	SeatType string // 座席类型代号
-	BedPos   int    // 卧铺位置：0 - 不限，3 - 上铺，2 - 中铺，1 - 下铺
+	TicketType int    // 车票类型：1 - 成人票，2 - 儿童票，3 - 学生票，4 - 残军票，可以和乘客类型不同（如用成人的证件购买儿童票）
+	SeatType   string // 座席类型代号
+	BedPos     int    // 卧铺位置：0 - 不限，3 - 上铺，2 - 中铺，1 - 下铺
 }
 
 type PassengerTicketInfos []*PassengerTicketInfo
 
 func (p PassengerTicketInfos) Names() (ret string) {
 	for _, passenger := range p {
-		ret += passenger.PassengerName + "，"
+		ret += passenger.PassengerName
+		if passenger.TicketType != TicketTypeAdult {
+			ret += "（" + TicketTypeName(passenger.TicketType) + "）"
+		}
+		ret += "，"
 	}
 
 	return strings.TrimSuffix(ret, "，")
@@ -0,0 +1,290 @@
+package common
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+type StationInfo struct {
+	ID           int           `json:"id"`
+	TelegramCode string        `json:"telegram_code"` // 电报码
+	StationName  string        `json:"station_name"`  // 站点名
+	PinYin       string        `json:"pinyin"`        // 拼音
+	PY           string        `json:"py"`            // 拼音首字母
+	PYCode       string        `json:"py_code"`       // 拼音码
+	CityName     string        `json:"city_name"`     // 所在城市，站点列表中没有城市信息时为空
+	SaleTime     time.Duration `json:"sale_time"`     // 站点开售时间
+}
+
+// Stations 站点列表，Key: 站点 ID
+type Stations struct {
+	stations map[int]*StationInfo
+
+	byName         map[string]*StationInfo   // Key: 站点名
+	byTelegramCode map[string]*StationInfo   // Key: 电报码
+	byPinYin       map[string][]*StationInfo // Key: 小写的拼音、拼音首字母、拼音码，可能有多个站点
+	byCity         map[string][]*StationInfo // Key: 去掉“市”的城市名
+}
+
+func NewStations() *Stations {
+	return &Stations{
+		stations:       make(map[int]*StationInfo),
+		byName:         make(map[string]*StationInfo),
+		byTelegramCode: make(map[string]*StationInfo),
+		byPinYin:       make(map[string][]*StationInfo),
+		byCity:         make(map[string][]*StationInfo),
+	}
+}
+
+// Add 添加站点，站点 ID 已存在时返回 false
+func (s *Stations) Add(stationInfo *StationInfo) bool {
+	if s.stations[stationInfo.ID] != nil {
+		return false
+	}
+
+	s.stations[stationInfo.ID] = stationInfo
+	s.byName[stationInfo.StationName] = stationInfo
+	s.byTelegramCode[stationInfo.TelegramCode] = stationInfo
+
+	for _, key := range stationInfo.pinYinKeys() {
+		s.byPinYin[key] = append(s.byPinYin[key], stationInfo)
+	}
+
+	if city := normalizeCity(stationInfo.CityName); city != "" {
+		s.byCity[city] = append(s.byCity[city], stationInfo)
+	}
+
+	return true
+}
+
+// pinYinKeys 站点的拼音、拼音首字母、拼音码，已去重并转为小写
+func (s *StationInfo) pinYinKeys() (keys []string) {
+	for _, key := range []string{s.PinYin, s.PY, s.PYCode} {
+		key = strings.ToLower(key)
+		if key != "" && !inStrings(key, keys) {
+			keys = append(keys, key)
+		}
+	}
+
+	return
+}
+
+func inStrings(s string, arr []string) bool {
+	for _, a := range arr {
+		if a == s {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Stations) Len() int {
+	return len(s.stations)
+}
+
+// All 所有站点，按站点 ID 排序
+func (s *Stations) All() (stations []*StationInfo) {
+	stations = make([]*StationInfo, 0, len(s.stations))
+	for _, station := range s.stations {
+		stations = append(stations, station)
+	}
+
+	return sortStations(stations)
+}
+
+func (s *Stations) StationNameToStationInfo(stationName string) (stationInfo *StationInfo) {
+	return s.byName[stationName]
+}
+
+func (s *Stations) StationTelegramCodeToStationInfo(telegramCode string) (stationInfo *StationInfo) {
+	return s.byTelegramCode[telegramCode]
+}
+
+// Lookup 按站点名、电报码、拼音、拼音首字母或拼音码查找站点，如 广州南、IZQ、guangzhounan、gzn
+// 找不到或匹配到多个站点时返回错误
+func (s *Stations) Lookup(key string) (stationInfo *StationInfo, err error) {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return nil, fmt.Errorf("站点不能为空")
+	}
+
+	if stationInfo = s.byName[key]; stationInfo != nil {
+		return
+	}
+
+	if stationInfo = s.byTelegramCode[strings.ToUpper(key)]; stationInfo != nil {
+		return
+	}
+
+	matched := s.byPinYin[strings.ToLower(key)]
+	switch len(matched) {
+	case 0:
+		return nil, fmt.Errorf("站点 %q 不存在", key)
+
+	case 1:
+		return matched[0], nil
+
+	default:
+		names := make([]string, 0, len(matched))
+		for _, station := range sortStations(matched) {
+			names = append(names, station.StationName)
+		}
+
+		return nil, fmt.Errorf("%q 匹配到多个站点: %s", key, strings.Join(names, "、"))
+	}
+}
+
+// normalizeCity 去掉城市名的“市”，如 广州市 和 广州 都是 广州
+func normalizeCity(cityName string) string {
+	return strings.TrimSuffix(strings.TrimSpace(cityName), "市")
+}
+
+// CityStations 城市内的所有站点，按站点 ID 排序，城市名可以带或不带“市”
+// 按站点列表中的城市名查找，站点列表中没有城市信息时返回空
+func (s *Stations) CityStations(cityName string) (stations []*StationInfo) {
+	return sortStations(append([]*StationInfo{}, s.byCity[normalizeCity(cityName)]...))
+}
+
+// Resolve 解析任务配置中的出发站或到达站，返回所有站点：
+// 多个站点用逗号分隔，如 广州南,广州东；以“市”结尾表示城市内的所有站点，如 广州市；
+// 其他按 Lookup 查找单个站点，找不到时按城市查找
+func (s *Stations) Resolve(key string) (stations []*StationInfo, err error) {
+	parts := strings.FieldsFunc(key, func(c rune) bool {
+		return c == ',' || c == '，'
+	})
+	if len(parts) == 0 {
+		return nil, fmt.Errorf("站点不能为空")
+	}
+
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+
+		// 先按站点查找，找不到时按城市查找，城市名可以不带“市”（如 深圳 没有同名站点时为深圳市的所有站点）
+		var matched []*StationInfo
+		if station, e := s.Lookup(part); e == nil {
+			matched = []*StationInfo{station}
+		} else if matched = s.CityStations(part); len(matched) == 0 {
+			if strings.HasSuffix(part, "市") {
+				return nil, fmt.Errorf("城市 %q 没有站点", part)
+			}
+
+			return nil, e
+		}
+
+		for _, station := range matched {
+			if !containsStation(stations, station) {
+				stations = append(stations, station)
+			}
+		}
+	}
+
+	return
+}
+
+func containsStation(stations []*StationInfo, station *StationInfo) bool {
+	for _, s := range stations {
+		if s == station {
+			return true
+		}
+	}
+
+	return false
+}
+
+// StationNames 站点名，用 / 连接
+func StationNames(stations []*StationInfo) string {
+	names := make([]string, 0, len(stations))
+	for _, station := range stations {
+		names = append(names, station.StationName)
+	}
+
+	return strings.Join(names, "/")
+}
+
+// 搜索结果的匹配程度，数值越小越靠前
+const (
+	matchExact = iota
+	matchPrefix
+	matchContains
+	matchFuzzy
+	matchNone
+)
+
+// isSubsequence sub 中的字符是否按顺序出现在 s 中，如 gzhn 与 guangzhounan
+func isSubsequence(sub, s string) bool {
+	i := 0
+	for _, c := range s {
+		if i < len(sub) && rune(sub[i]) == c {
+			i++
+		}
+	}
+
+	return i == len(sub)
+}
+
+// match 关键字与站点的匹配程度
+func (s *StationInfo) match(keyword string) int {
+	lower := strings.ToLower(keyword)
+	if s.StationName == keyword || strings.EqualFold(s.TelegramCode, keyword) || inStrings(lower, s.pinYinKeys()) {
+		return matchExact
+	}
+
+	if strings.HasPrefix(s.StationName, keyword) {
+		return matchPrefix
+	}
+
+	for _, key := range s.pinYinKeys() {
+		if strings.HasPrefix(key, lower) {
+			return matchPrefix
+		}
+	}
+
+	if strings.Contains(s.StationName, keyword) || strings.Contains(strings.ToLower(s.PinYin), lower) {
+		return matchContains
+	}
+
+	// 只对拼音做模糊匹配，汉字关键字不做
+	if lower != "" && lower[0] < 0x80 && isSubsequence(lower, strings.ToLower(s.PinYin)) {
+		return matchFuzzy
+	}
+
+	return matchNone
+}
+
+func sortStations(stations []*StationInfo) []*StationInfo {
+	sort.Slice(stations, func(i, j int) bool {
+		return stations[i].ID < stations[j].ID
+	})
+
+	return stations
+}
+
+// Search 按站点名、电报码、拼音、拼音首字母或拼音码搜索站点
+// 结果按完全匹配、前缀匹配、包含、拼音模糊匹配的顺序排列，同一匹配程度按站点 ID 排序
+func (s *Stations) Search(keyword string) (stations []*StationInfo) {
+	keyword = strings.TrimSpace(keyword)
+	if keyword == "" {
+		return
+	}
+
+	matches := make(map[*StationInfo]int)
+	for _, station := range s.stations {
+		if m := station.match(keyword); m != matchNone {
+			matches[station] = m
+			stations = append(stations, station)
+		}
+	}
+
+	sort.Slice(stations, func(i, j int) bool {
+		if matches[stations[i]] != matches[stations[j]] {
+			return matches[stations[i]] < matches[stations[j]]
+		}
+
+		return stations[i].ID < stations[j].ID
+	})
+
+	return
+}
@@ -0,0 +1,135 @@
+package common_test
+
+import (
+	"gogo12306/common"
+	"testing"
+)
+
+func newTestStations() *common.Stations {
+	stations := common.NewStations()
+	for _, station := range []*common.StationInfo{
+		{ID: 5, StationName: "广州南", TelegramCode: "IZQ", PinYin: "guangzhounan", PY: "gzn", PYCode: "gzn"},
+		{ID: 33, StationName: "广州", TelegramCode: "GZQ", PinYin: "guangzhou", PY: "gz", PYCode: "gzh"},
+		{ID: 34, StationName: "广州东", TelegramCode: "GGQ", PinYin: "guangzhoudong", PY: "gzd", PYCode: "gzd"},
+		{ID: 40, StationName: "赣州", TelegramCode: "GZG", PinYin: "ganzhou", PY: "gz", PYCode: "gzh"},
+		{ID: 60, StationName: "上海虹桥", TelegramCode: "AOH", PinYin: "shanghaihongqiao", PY: "shhq", PYCode: "hqh"},
+	} {
+		stations.Add(station)
+	}
+
+	return stations
+}
+
+func TestStationsLookup(t *testing.T) {
+	stations := newTestStations()
+
+	for key, want := range map[string]string{
+		"广州南":          "广州南",
+		"IZQ":          "广州南",
+		"izq":          "广州南",
+		"gzn":          "广州南",
+		"guangzhounan": "广州南",
+		"GuangZhou":    "广州",
+		"hqh":          "上海虹桥",
+		" shhq ":       "上海虹桥",
+	} {
+		station, err := stations.Lookup(key)
+		if err != nil {
+			t.Errorf("%q: %s", key, err.Error())
+		} else if station.StationName != want {
+			t.Errorf("%q: got %s, want %s", key, station.StationName, want)
+		}
+	}
+
+	// 广州和赣州的拼音码都是 gzh
+	for _, key := range []string{"gzh", "gz", "北京", ""} {
+		if station, err := stations.Lookup(key); err == nil {
+			t.Errorf("%q should fail, got %s", key, station.StationName)
+		}
+	}
+}
+
+func TestStationsSearch(t *testing.T) {
+	stations := newTestStations()
+
+	for keyword, want := range map[string][]string{
+		"广州":    {"广州", "广州南", "广州东"},
+		"gzd":   {"广州东"},
+		"guang": {"广州南", "广州", "广州东"},
+		"gzh":   {"广州", "赣州", "广州南", "广州东"},
+		"hongq": {"上海虹桥"},
+		"shhq":  {"上海虹桥"},
+		"gzhdg": {"广州东"},
+		"xyz":   nil,
+	} {
+		var got []string
+		for _, station := range stations.Search(keyword) {
+			got = append(got, station.StationName)
+		}
+
+		if len(got) != len(want) {
+			t.Errorf("%q: got %v, want %v", keyword, got, want)
+			continue
+		}
+
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%q: got %v, want %v", keyword, got, want)
+				break
+			}
+		}
+	}
+}
+
+func TestStationsResolve(t *testing.T) {
+	stations := newTestStations()
+
+	for key, want := range map[string]string{
+		"广州南，gzd":     "广州南/广州东",
+		"上海虹桥":        "上海虹桥",
+		" IZQ , AOH ": "广州南/上海虹桥",
+	} {
+		resolved, err := stations.Resolve(key)
+		if err != nil {
+			t.Errorf("%q: %s", key, err.Error())
+		} else if got := common.StationNames(resolved); got != want {
+			t.Errorf("%q: got %s, want %s", key, got, want)
+		}
+	}
+
+	// 没有城市信息时不能按城市查找，也不按站名前缀推断
+	for _, key := range []string{"", ",", "广州市", "北京市", "广州南,北京"} {
+		if _, err := stations.Resolve(key); err == nil {
+			t.Errorf("%q should fail", key)
+		}
+	}
+
+	// 有城市信息时按城市查找，城市名可以带或不带“市”
+	stations = common.NewStations()
+	stations.Add(&common.StationInfo{ID: 1, StationName: "武昌", TelegramCode: "WCN", CityName: "武汉"})
+	stations.Add(&common.StationInfo{ID: 2, StationName: "汉口", TelegramCode: "HKN", CityName: "武汉"})
+	stations.Add(&common.StationInfo{ID: 3, StationName: "武汉", TelegramCode: "WHN", CityName: "武汉"})
+	stations.Add(&common.StationInfo{ID: 4, StationName: "武汉东", TelegramCode: "LFN", CityName: "武汉"})
+	stations.Add(&common.StationInfo{ID: 5, StationName: "广州南", TelegramCode: "IZQ", CityName: "广州"})
+	stations.Add(&common.StationInfo{ID: 6, StationName: "深圳北", TelegramCode: "IOQ", CityName: "深圳市"})
+	stations.Add(&common.StationInfo{ID: 7, StationName: "武汉北", TelegramCode: "WBN", CityName: "阳逻"})
+
+	for key, want := range map[string]string{
+		"武汉市":     "武昌/汉口/武汉/武汉东",
+		"武汉":      "武汉", // 有同名站点时为单个站点
+		"深圳":      "深圳北",
+		"深圳市":     "深圳北",
+		"广州,深圳市北": "",
+	} {
+		resolved, err := stations.Resolve(key)
+		if want == "" {
+			if err == nil {
+				t.Errorf("%q should fail", key)
+			}
+		} else if err != nil {
+			t.Errorf("%q: %s", key, err.Error())
+		} else if got := common.StationNames(resolved); got != want {
+			t.Errorf("%q: got %s, want %s", key, got, want)
+		}
+	}
+}
@@ -0,0 +1,32 @@
+package common
+
+// TrainStop 列车时刻表中的一个停靠站
+type TrainStop struct {
+	StationNo    int    // 站序，从 1 开始
+	StationName  string // 站点名
+	TelegramCode string // 电报码，站点列表中没有该站点时为空
+	ArriveTime   string // 到达时间 HH:MM，始发站为空
+	DepartTime   string // 出发时间 HH:MM，终到站为空
+	StopMinutes  int    // 停车时间，单位: 分钟
+	ArriveDay    int    // 到达时是列车始发后的第几天，0 为始发当天
+	DepartDay    int    // 出发时是列车始发后的第几天，0 为始发当天
+}
+
+// TrainStops 列车的停靠站列表，按站序排列
+type TrainStops []*TrainStop
+
+// Index 按电报码或站点名查找停靠站的下标，不停靠时返回 -1
+func (stops TrainStops) Index(station string) int {
+	for i, stop := range stops {
+		if (stop.TelegramCode != "" && stop.TelegramCode == station) || stop.StationName == station {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// StopsAt 列车是否停靠该站
+func (stops TrainStops) StopsAt(station string) bool {
+	return stops.Index(station) >= 0
+}
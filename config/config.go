@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"log"
+	"path/filepath"
+	"strings"
 )
 
 type LoggerConfig struct {
@@ -19,6 +22,19 @@ type CDNConfig struct {
 	GoodCDNPath string `json:"good_cdn_path"`
 }
 
+type ServerConfig struct {
+	Host    string `json:"host"`     // kyfw.12306.cn 的替代地址，留空则使用官方网站或 CDN
+	WWWHost string `json:"www_host"` // www.12306.cn 的替代地址，留空则使用官方网站
+
+	MockAddr     string `json:"mock_addr"`     // 本地模拟服务器监听地址
+	MockFixtures string `json:"mock_fixtures"` // 本地模拟服务器的自定义录制数据目录，留空则使用内置数据
+}
+
+type StationsConfig struct {
+	CachePath   string `json:"cache_path"`    // 站点列表缓存文件路径，留空则不缓存
+	MaxAgeHours int    `json:"max_age_hours"` // 缓存的有效时间，单位: 小时，过期后重新获取
+}
+
 type LoginConfig struct {
 	GetCookieMethod int `json:"get_cookie_method"`
 
@@ -31,11 +47,48 @@ type LoginConfig struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 
-	LoginMethod int `json:"login_method"`
+	LoginMethod int    `json:"login_method"`  // 登录方式，0 - 用户名密码登录，1 - 扫二维码登录
+	QRImagePath string `json:"qr_image_path"` // 扫码登录时二维码图像的保存路径
+
+	CaptchaSolver    string `json:"captcha_solver"`     // 验证码识别方式，ocr - 自建 OCR 服务，manual - 终端手动输入，stub - 固定答案（测试用）
+	CaptchaImagePath string `json:"captcha_image_path"` // 手动输入验证码时验证码图像的保存路径
+	CaptchaRetries   int    `json:"captcha_retries"`    // 登录和提交订单时验证码识别或校验失败的最多尝试次数
+	OCRUrl           string `json:"ocr_url"`
+
+	CastNum     string `json:"cast_num"`      // 证件号码后 4 位，短信验证码登录时使用
+	SMSCodeAddr string `json:"sms_code_addr"` // 提交短信验证码的本地 HTTP 接口监听地址，留空则从标准输入读取
+
+	SessionDir string `json:"session_dir"` // 登录会话保存目录，留空则不保存
+	SessionKey string `json:"session_key"` // 登录会话文件的加密密钥，留空则使用登录密码
+}
+
+// 二维码和验证码图像的默认保存路径
+const (
+	DefaultQRImagePath      = "qrcode.png"
+	DefaultCaptchaImagePath = "captcha.jpg"
+)
+
+// AccountConfig 多账号时每个账号的配置，留空的字段使用 login 里的同名配置
+type AccountConfig struct {
+	Name string `json:"name"` // 账号名，任务通过 account 字段引用
+
+	Username string `json:"username"`
+	Password string `json:"password"`
+
+	RailExpiration string `json:"rail_expiration"`
+	RailDeviceID   string `json:"rail_device_id"`
 
-	OCRUrl string `json:"ocr_url"`
+	LoginMethod *int   `json:"login_method"`  // 登录方式，留空使用 login 里的配置
+	QRImagePath string `json:"qr_image_path"` // 留空时在 login 里的路径（默认 qrcode.png）的文件名后加上账号名，如 qrcode_账号名.png
+
+	CaptchaSolver    string `json:"captcha_solver"`
+	CaptchaImagePath string `json:"captcha_image_path"` // 留空时在 login 里的路径（默认 captcha.jpg）的文件名后加上账号名
+	CaptchaRetries   int    `json:"captcha_retries"`
+	OCRUrl           string `json:"ocr_url"`
 
 	CastNum string `json:"cast_num"`
+
+	SessionKey string `json:"session_key"`
 }
 
 type ServerChan struct {
@@ -55,8 +108,21 @@ type NotifierConfig struct {
 	WXPusher   `json:"wxpusher,omitempty"`
 }
 
+// TrainFilterConfig 车次筛选规则，字段为空时不筛选，所有规则都符合的车次才会下单
+type TrainFilterConfig struct {
+	TrainTypes         string   `json:"train_types"`          // 车次类型，车次首字母的组合，如 GD 只购买高铁和动车
+	Depart             string   `json:"depart"`               // 出发时间范围，HH:MM-HH:MM，可跨零点如 22:00-06:00
+	Arrive             string   `json:"arrive"`               // 到达时间范围，格式同 depart
+	MaxDurationMinutes int      `json:"max_duration_minutes"` // 最长历时，单位: 分钟，0 为不限
+	FromStations       []string `json:"from_stations"`        // 只购买从这些站点出发的车次，格式同 from，用于 from 为城市时限定车站
+	ToStations         []string `json:"to_stations"`          // 只购买到达这些站点的车次，格式同 to
+}
+
 type TaskConfig struct {
-	QueryOnly bool `json:"query_only"`
+	Account   string `json:"account"` // 使用的账号名，留空则使用第一个账号
+	QueryOnly bool   `json:"query_only"`
+
+	QueryFormat string `json:"query_format"` // 仅查询时余票的输出格式：table/json/csv，留空为 table
 
 	OrderType int `json:"order_type"` // 1 - 普通购票，2 - 候补票/刷票
 	BlackTime int `json:"black_time"`
@@ -64,49 +130,153 @@ type TaskConfig struct {
 	AllowCandidate    bool `json:"allow_candidate"`    // 是否抢候补票
 	CandidateDeadline int  `json:"candidate_deadline"` // 候补票距离开车前的截止兑换时间
 
+	TicketType string `json:"ticket_type"` // 车票类型：adult - 成人票，student - 学生票，留空为成人票
+
 	From string `json:"from"`
 	To   string `json:"to"`
 
 	StartDates []string `json:"start_dates"`
 
-	TrainCodes []string `json:"train_codes"`
+	TrainCodes        []string           `json:"train_codes"`
+	TrainFilter       *TrainFilterConfig `json:"train_filter"`        // 按规则筛选车次，符合规则的车次与 train_codes 一样尝试下单
+	ExcludeTrainCodes []string           `json:"exclude_train_codes"` // 不购买的车次，优先于 train_codes 和 train_filter
+
+	BackStartDates []string `json:"back_start_dates"` // 往返票的返程日期，不为空时为往返票，去程下单成功后抢返程（到达站到出发站）
+	BackTrainCodes []string `json:"back_train_codes"` // 往返票的返程车次
+
+	Transfer           bool     `json:"transfer"`             // 直达车次没有下单成功时，查询经过中转站的两程车票并一起下单
+	TransferHubs       []string `json:"transfer_hubs"`        // 中转站，格式同 from，留空则使用默认的中转城市
+	TransferMinMinutes int      `json:"transfer_min_minutes"` // 最短换乘时间，单位: 分钟，0 为默认的 30 分钟
+	TransferMaxMinutes int      `json:"transfer_max_minutes"` // 最长换乘时间，单位: 分钟，0 为默认的 240 分钟
+	TransferMaxHubs    int      `json:"transfer_max_hubs"`    // 最多查询的中转站数量，0 为默认的 8 个
+	TransferAfter      int      `json:"transfer_after"`       // 直达车次连续多少轮没有下单成功后才查询中转方案，0 为默认的 3 轮
+	TransferInterval   int      `json:"transfer_interval"`    // 同一出发日期两次查询中转方案的最短间隔，单位: 秒，0 为默认的 300 秒
+
+	LongerSegment         bool `json:"longer_segment"`          // 买长乘短：指定车次的原区间没有余票时，购买同一车次覆盖行程的更长区间（始发站、终到站）
+	LongerSegmentInterval int  `json:"longer_segment_interval"` // 同一车次两次查询更长区间的最短间隔，单位: 秒，0 为默认的 60 秒
 
 	Seats          []string `json:"seats"`
 	ChooseSeats    []string `json:"choose_seats"`
 	SeatDetailType []string `json:"seat_detail_type"`
 	AllowNoSeat    bool     `json:"allow_no_seat"` // 允许提交系统分配的无座票
 
+	MaxPrice  float64            `json:"max_price"`  // 最高票价，单位: 元，票价超过时不下单，0 为不限
+	MaxPrices map[string]float64 `json:"max_prices"` // 各座席的最高票价，Key 为座席类型，优先于 max_price
+
+	MaxUpgradePrice float64 `json:"max_upgrade_price"` // 升级座席的最高差价，单位: 元，seats 中靠后的座席比前面的座席贵出超过时不下单，0 为不限
+
 	Passengers  []string `json:"passengers"`
 	UUIDs       []string `json:"uuids"`
+	TicketTypes []string `json:"ticket_types"` // 每个乘客的车票类型，与 passengers/uuids 一一对应，留空则按任务和乘客类型确定
 	AllowPartly bool     `json:"allow_partly"` // 允许部分提交
 }
 
 type Config struct {
-	Logger   LoggerConfig   `json:"logger"`
-	CDN      CDNConfig      `json:"cdn"`
-	Login    LoginConfig    `json:"login"`
-	Notifier NotifierConfig `json:"notifier"`
-	Tasks    []TaskConfig   `json:"tasks"`
-
-	StudentPresellDays int // 学生票预售提前天数
-	OtherPresellDays   int // 一般车票预售提前天数
-}
-
-var Cfg Config
-
-func Init(cfgPath string) {
-	if cfgData, err := ioutil.ReadFile(cfgPath); err != nil {
-		log.Fatalf("read config err: %s", err.Error())
-	} else {
-		cfg := Config{}
-		if err = json.Unmarshal(cfgData, &cfg); err != nil {
-			log.Fatalf("config unmarshal err: %s", err.Error())
-		} else {
-			Cfg = cfg
-
-			// 暂定 15 天，后面的 NeedCaptcha 接口可以获取正确数值
-			Cfg.StudentPresellDays = 15
-			Cfg.OtherPresellDays = 15
+	Logger   LoggerConfig    `json:"logger"`
+	CDN      CDNConfig       `json:"cdn"`
+	Server   ServerConfig    `json:"server"`
+	Stations StationsConfig  `json:"stations"`
+	Login    LoginConfig     `json:"login"`
+	Accounts []AccountConfig `json:"accounts"`
+	Notifier NotifierConfig  `json:"notifier"`
+	Tasks    []TaskConfig    `json:"tasks"`
+}
+
+// AccountLogins 返回每个账号的登录配置，Key: 账号名
+// 没有配置 accounts 时只有一个账号名为空的账号，使用 login 里的配置
+func (c *Config) AccountLogins() (names []string, logins map[string]*LoginConfig) {
+	logins = make(map[string]*LoginConfig)
+	if len(c.Accounts) == 0 {
+		return []string{""}, map[string]*LoginConfig{"": &c.Login}
+	}
+
+	for _, account := range c.Accounts {
+		loginCfg := c.Login
+		loginCfg.Username = account.Username
+		loginCfg.Password = account.Password
+
+		if account.RailExpiration != "" {
+			loginCfg.RailExpiration = account.RailExpiration
 		}
+
+		if account.RailDeviceID != "" {
+			loginCfg.RailDeviceID = account.RailDeviceID
+		}
+
+		if account.LoginMethod != nil {
+			loginCfg.LoginMethod = *account.LoginMethod
+		}
+
+		// 多个账号同时登录时，图像保存到不同的文件，避免互相覆盖
+		loginCfg.QRImagePath = accountFilePath(account.QRImagePath, c.Login.QRImagePath, DefaultQRImagePath, account.Name)
+		loginCfg.CaptchaImagePath = accountFilePath(account.CaptchaImagePath, c.Login.CaptchaImagePath, DefaultCaptchaImagePath, account.Name)
+
+		if account.CaptchaSolver != "" {
+			loginCfg.CaptchaSolver = account.CaptchaSolver
+		}
+
+		if account.CaptchaRetries > 0 {
+			loginCfg.CaptchaRetries = account.CaptchaRetries
+		}
+
+		if account.OCRUrl != "" {
+			loginCfg.OCRUrl = account.OCRUrl
+		}
+
+		if account.CastNum != "" {
+			loginCfg.CastNum = account.CastNum
+		}
+
+		if account.SessionKey != "" {
+			loginCfg.SessionKey = account.SessionKey
+		}
+
+		names = append(names, account.Name)
+		logins[account.Name] = &loginCfg
+	}
+
+	return
+}
+
+// accountFilePath 账号的文件保存路径，账号没有配置时在共用路径的文件名后加上账号名
+func accountFilePath(accountPath, loginPath, defaultPath, name string) string {
+	if accountPath != "" {
+		return accountPath
 	}
+
+	if loginPath == "" {
+		loginPath = defaultPath
+	}
+
+	if name == "" {
+		return loginPath
+	}
+
+	ext := filepath.Ext(loginPath)
+	return strings.TrimSuffix(loginPath, ext) + "_" + name + ext
+}
+
+// Load 读取配置文件
+func Load(cfgPath string) (cfg *Config, err error) {
+	var cfgData []byte
+	if cfgData, err = ioutil.ReadFile(cfgPath); err != nil {
+		return nil, fmt.Errorf("read config err: %s", err.Error())
+	}
+
+	cfg = &Config{}
+	if err = json.Unmarshal(cfgData, cfg); err != nil {
+		return nil, fmt.Errorf("config unmarshal err: %s", err.Error())
+	}
+
+	return
+}
+
+// Init 读取配置文件，出错时直接退出程序
+func Init(cfgPath string) (cfg *Config) {
+	var err error
+	if cfg, err = Load(cfgPath); err != nil {
+		log.Fatal(err.Error())
+	}
+
+	return
 }
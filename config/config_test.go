@@ -0,0 +1,101 @@
+package config_test
+
+import (
+	"gogo12306/config"
+	"strings"
+	"testing"
+)
+
+func TestAccountLogins(t *testing.T) {
+	cfg := config.Config{
+		Login: config.LoginConfig{
+			GetCookieMethod: 3,
+			RailDeviceID:    "device",
+			CastNum:         "1234",
+		},
+	}
+
+	names, logins := cfg.AccountLogins()
+	if len(names) != 1 || names[0] != "" || logins[""] != &cfg.Login {
+		t.Error("default account error")
+		return
+	}
+
+	cfg.Accounts = []config.AccountConfig{
+		{Name: "a", Username: "user_a", Password: "pwd_a"},
+		{Name: "b", Username: "user_b", Password: "pwd_b", CastNum: "5678"},
+	}
+
+	names, logins = cfg.AccountLogins()
+	if len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Errorf("account names error: %v", names)
+		return
+	}
+
+	if logins["a"].Username != "user_a" || logins["a"].RailDeviceID != "device" || logins["a"].CastNum != "1234" {
+		t.Errorf("account a error: %+v", logins["a"])
+	}
+
+	if logins["b"].Username != "user_b" || logins["b"].GetCookieMethod != 3 || logins["b"].CastNum != "5678" {
+		t.Errorf("account b error: %+v", logins["b"])
+	}
+
+	if logins["a"].QRImagePath != "qrcode_a.png" || logins["b"].CaptchaImagePath != "captcha_b.jpg" {
+		t.Errorf("default image path error: %s, %s", logins["a"].QRImagePath, logins["b"].CaptchaImagePath)
+	}
+
+	qr := 1
+	cfg.Login.QRImagePath = "data/qr.png"
+	cfg.Accounts = append(cfg.Accounts, config.AccountConfig{
+		Name:          "c",
+		LoginMethod:   &qr,
+		QRImagePath:   "c.png",
+		CaptchaSolver: "manual",
+		SessionKey:    "key_c",
+	})
+
+	_, logins = cfg.AccountLogins()
+	if logins["a"].QRImagePath != "data/qr_a.png" || logins["a"].LoginMethod != 0 || logins["a"].SessionKey != "" {
+		t.Errorf("account a error: %+v", logins["a"])
+	}
+
+	if c := logins["c"]; c.LoginMethod != 1 || c.QRImagePath != "c.png" || c.CaptchaSolver != "manual" || c.SessionKey != "key_c" {
+		t.Errorf("account c error: %+v", c)
+	}
+
+	if cfg.Login.Username != "" {
+		t.Error("login config modified")
+	}
+}
+
+func TestValidateLogin(t *testing.T) {
+	cfg := &config.Config{Login: config.LoginConfig{LoginMethod: 2}}
+
+	paths := make(map[string]bool)
+	for _, p := range cfg.Validate() {
+		paths[p.Path] = true
+	}
+
+	if !paths["login.login_method"] || paths["login.username"] {
+		t.Errorf("login_method 2: unexpected problems %v", paths)
+	}
+
+	// 用户名密码登录时需要用户名和密码
+	cfg.Login.LoginMethod = 0
+	paths = make(map[string]bool)
+	for _, p := range cfg.Validate() {
+		paths[p.Path] = true
+	}
+
+	if paths["login.login_method"] || !paths["login.username"] || !paths["login.password"] {
+		t.Errorf("login_method 0: unexpected problems %v", paths)
+	}
+
+	// 配置了多账号时不检查 login 里的用户名密码
+	cfg.Accounts = []config.AccountConfig{{Name: "a", Username: "user_a", Password: "pwd_a"}}
+	for _, p := range cfg.Validate() {
+		if strings.HasPrefix(p.Path, "login.") {
+			t.Errorf("accounts set: unexpected problem %s", p.String())
+		}
+	}
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Problem 配置中的一个问题，Path 是出问题的字段在 JSON 中的路径，如 tasks[0].start_dates[1]
+type Problem struct {
+	Path    string
+	Message string
+}
+
+func (p Problem) String() string {
+	return p.Path + ": " + p.Message
+}
+
+// Problems 配置中的所有问题
+type Problems []Problem
+
+func (ps Problems) Error() string {
+	var arr []string
+	for _, p := range ps {
+		arr = append(arr, p.String())
+	}
+
+	return strings.Join(arr, "; ")
+}
+
+// Add 添加一个问题，path 和 message 支持 fmt 格式化参数
+func (ps *Problems) Add(path, format string, args ...interface{}) {
+	*ps = append(*ps, Problem{Path: path, Message: fmt.Sprintf(format, args...)})
+}
+
+// Validate 检查配置本身的问题（不需要联网），返回所有发现的问题
+func (c *Config) Validate() (problems Problems) {
+	// 登录
+	if c.Login.LoginMethod != 0 && c.Login.LoginMethod != 1 {
+		problems.Add("login.login_method", "登录方式只能是 0（用户名密码）或 1（扫二维码）")
+	}
+
+	// 没有配置多账号时使用 login 里的用户名密码登录
+	if len(c.Accounts) == 0 && c.Login.LoginMethod == 0 {
+		if c.Login.Username == "" {
+			problems.Add("login.username", "用户名密码登录时用户名不能为空")
+		}
+
+		if c.Login.Password == "" {
+			problems.Add("login.password", "用户名密码登录时密码不能为空")
+		}
+	}
+
+	// 账号
+	accounts := make(map[string]bool)
+	for i, account := range c.Accounts {
+		path := fmt.Sprintf("accounts[%d]", i)
+		if len(c.Accounts) > 1 && account.Name == "" {
+			problems.Add(path+".name", "多账号时账号名不能为空")
+		}
+
+		if accounts[account.Name] {
+			problems.Add(path+".name", "账号名 %q 重复", account.Name)
+		}
+		accounts[account.Name] = true
+
+		if account.LoginMethod != nil && *account.LoginMethod != 0 && *account.LoginMethod != 1 {
+			problems.Add(path+".login_method", "登录方式只能是 0（用户名密码）或 1（扫二维码）")
+		}
+
+		if account.CaptchaRetries < 0 {
+			problems.Add(path+".captcha_retries", "验证码尝试次数不能为负数")
+		}
+	}
+
+	// 任务
+	tasks := make(map[string]int) // Key: 任务配置的 JSON，Value: 任务序号
+	for i, task := range c.Tasks {
+		path := fmt.Sprintf("tasks[%d]", i)
+
+		if task.Account != "" && !accounts[task.Account] {
+			problems.Add(path+".account", "账号 %q 不存在", task.Account)
+		}
+
+		// 相同的任务会重复下单，未指定账号时按第一个账号比较
+		same := task
+		if same.Account == "" && len(c.Accounts) > 0 {
+			same.Account = c.Accounts[0].Name
+		}
+
+		if key, err := json.Marshal(&same); err == nil {
+			if j, ok := tasks[string(key)]; ok {
+				problems.Add(path, "与 tasks[%d] 的配置相同", j)
+			} else {
+				tasks[string(key)] = i
+			}
+		}
+
+		if task.OrderType != 1 && task.OrderType != 2 {
+			problems.Add(path+".order_type", "下单方式只能是 1 或 2")
+		}
+
+		switch task.QueryFormat {
+		case "", "table", "json", "csv":
+		default:
+			problems.Add(path+".query_format", "输出格式只能是 table、json 或 csv")
+		}
+
+		if strings.TrimSpace(task.From) == "" {
+			problems.Add(path+".from", "出发站不能为空")
+		}
+
+		if strings.TrimSpace(task.To) == "" {
+			problems.Add(path+".to", "到达站不能为空")
+		}
+
+		if len(task.StartDates) == 0 {
+			problems.Add(path+".start_dates", "出发日期不能为空")
+		}
+
+		for j, date := range task.StartDates {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				problems.Add(fmt.Sprintf("%s.start_dates[%d]", path, j), "出发日期 %q 格式错误，应为 YYYY-MM-DD", date)
+			}
+		}
+
+		for j, date := range task.BackStartDates {
+			if _, err := time.Parse("2006-01-02", date); err != nil {
+				problems.Add(fmt.Sprintf("%s.back_start_dates[%d]", path, j), "返程日期 %q 格式错误，应为 YYYY-MM-DD", date)
+			}
+		}
+
+		if len(task.BackStartDates) > 0 {
+			if task.OrderType != 1 {
+				problems.Add(path+".order_type", "往返票只能使用普通购票（order_type 为 1）")
+			}
+
+			if task.AllowCandidate {
+				problems.Add(path+".allow_candidate", "往返票不能候补")
+			}
+		}
+
+		if task.Transfer {
+			if len(task.BackStartDates) > 0 {
+				problems.Add(path+".transfer", "往返票不能中转换乘")
+			}
+
+			if task.TransferMinMinutes < 0 || task.TransferMaxMinutes < 0 {
+				problems.Add(path+".transfer_min_minutes", "换乘时间不能为负数")
+			} else if task.TransferMaxMinutes > 0 && task.TransferMinMinutes > task.TransferMaxMinutes {
+				problems.Add(path+".transfer_max_minutes", "最长换乘时间 %d 分钟小于最短换乘时间 %d 分钟", task.TransferMaxMinutes, task.TransferMinMinutes)
+			}
+
+			if task.TransferMaxHubs < 0 || task.TransferAfter < 0 || task.TransferInterval < 0 {
+				problems.Add(path+".transfer", "transfer_max_hubs、transfer_after、transfer_interval 不能为负数")
+			}
+		}
+
+		if task.LongerSegmentInterval < 0 {
+			problems.Add(path+".longer_segment_interval", "查询更长区间的间隔不能为负数")
+		}
+
+		if task.LongerSegment && len(task.TrainCodes) == 0 && task.TrainFilter == nil {
+			problems.Add(path+".longer_segment", "买长乘短需要指定车次（train_codes）或车次筛选规则（train_filter）")
+		}
+
+		if task.TrainFilter != nil && task.TrainFilter.MaxDurationMinutes < 0 {
+			problems.Add(path+".train_filter.max_duration_minutes", "最长历时不能为负数")
+		}
+
+		if task.MaxPrice < 0 {
+			problems.Add(path+".max_price", "最高票价不能为负数")
+		}
+
+		if task.MaxUpgradePrice < 0 {
+			problems.Add(path+".max_upgrade_price", "升级座席的最高差价不能为负数")
+		}
+
+		for seatName, price := range task.MaxPrices {
+			if price < 0 {
+				problems.Add(path+".max_prices", "%s 的最高票价不能为负数", seatName)
+			}
+		}
+
+		if len(task.Seats) == 0 {
+			problems.Add(path+".seats", "座席类型不能为空")
+		}
+
+		if len(task.Passengers) == 0 && len(task.UUIDs) == 0 {
+			problems.Add(path+".passengers", "乘车人 passengers 和 uuids 不能都为空")
+		}
+
+		if len(task.TicketTypes) > 0 && len(task.TicketTypes) != len(task.Passengers) && len(task.TicketTypes) != len(task.UUIDs) {
+			problems.Add(path+".ticket_types", "车票类型数量 %d 和乘车人数量不一致", len(task.TicketTypes))
+		}
+
+		if len(task.SeatDetailType) != 3 {
+			problems.Add(path+".seat_detail_type", "选铺必须是 3 个值（下铺、中铺、上铺）")
+		}
+
+		if len(task.ChooseSeats) != len(task.Passengers) && len(task.ChooseSeats) != len(task.UUIDs) {
+			problems.Add(path+".choose_seats", "选座数量 %d 和乘车人数量不一致", len(task.ChooseSeats))
+		}
+	}
+
+	return
+}
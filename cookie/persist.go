@@ -0,0 +1,160 @@
+package cookie
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"gogo12306/logger"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// 需要保存 Cookies 的路径，cookiejar 无法遍历所有 Cookies，只能按网址逐个获取
+// 顺序是从上层路径到下层路径，下层路径只保存上层路径没有的 Cookies（如 /otn 的 tk，/passport 的 uamtk）
+var persistPaths = []string{
+	"/",
+	"/otn/",
+	"/passport/",
+}
+
+type persistCookie struct {
+	Path  string `json:"path"`
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func newGCM(key string) (gcm cipher.AEAD, err error) {
+	sum := sha256.Sum256([]byte(key))
+
+	var block cipher.Block
+	if block, err = aes.NewCipher(sum[:]); err != nil {
+		return
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// SaveJar 将 Jar 里 12306 的 Cookies 加密保存到文件
+// 登录时的 Cookies 保存在实际访问的 CDN 地址下，所以需要传入所有可能访问过的地址 hosts
+func SaveJar(jar *cookiejar.Jar, hosts []string, path, key string) (err error) {
+	var (
+		cookies []persistCookie
+		seen    = make(map[string]bool)
+	)
+	for _, p := range persistPaths {
+		for _, host := range hosts {
+			u := &url.URL{Scheme: "https", Host: host, Path: p}
+			for _, c := range jar.Cookies(u) {
+				if seen[c.Name] {
+					continue
+				}
+
+				seen[c.Name] = true
+				cookies = append(cookies, persistCookie{
+					Path:  p,
+					Name:  c.Name,
+					Value: c.Value,
+				})
+			}
+		}
+	}
+
+	var data []byte
+	if data, err = json.Marshal(cookies); err != nil {
+		logger.Error("序列化 Cookies 错误", zap.Error(err))
+
+		return
+	}
+
+	var gcm cipher.AEAD
+	if gcm, err = newGCM(key); err != nil {
+		logger.Error("创建加密器错误", zap.Error(err))
+
+		return
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		logger.Error("生成随机数错误", zap.Error(err))
+
+		return
+	}
+
+	if err = os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		logger.Error("创建会话目录错误", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	if err = ioutil.WriteFile(path, gcm.Seal(nonce, nonce, data, nil), 0600); err != nil {
+		logger.Error("写入会话文件错误", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	logger.Info("登录会话已保存", zap.String("path", path), zap.Int("Cookies 数量", len(cookies)))
+	return
+}
+
+// LoadJar 从加密文件恢复 Cookies 到 Jar 的主站 kyfw.12306.cn 下，文件不存在时返回 os.ErrNotExist
+func LoadJar(jar *cookiejar.Jar, path, key string) (err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		if !os.IsNotExist(err) {
+			logger.Error("读取会话文件错误", zap.String("path", path), zap.Error(err))
+		}
+
+		return
+	}
+
+	var gcm cipher.AEAD
+	if gcm, err = newGCM(key); err != nil {
+		logger.Error("创建加密器错误", zap.Error(err))
+
+		return
+	}
+
+	if len(data) < gcm.NonceSize() {
+		logger.Error("会话文件格式错误", zap.String("path", path))
+
+		return errors.New("session file too short")
+	}
+
+	if data, err = gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil); err != nil {
+		logger.Error("解密会话文件错误，可能是密钥已更改", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	var cookies []persistCookie
+	if err = json.Unmarshal(data, &cookies); err != nil {
+		logger.Error("解析会话文件错误", zap.String("path", path), zap.Error(err))
+
+		return
+	}
+
+	// 请求 CDN 时会自动附加主站的 Cookies
+	for _, c := range cookies {
+		u := &url.URL{Scheme: "https", Host: "kyfw.12306.cn", Path: c.Path}
+		jar.SetCookies(u, []*http.Cookie{
+			{
+				Name:  c.Name,
+				Value: c.Value,
+				Path:  c.Path,
+			},
+		})
+	}
+
+	logger.Info("已恢复登录会话", zap.String("path", path), zap.Int("Cookies 数量", len(cookies)))
+	return
+}
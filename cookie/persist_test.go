@@ -0,0 +1,39 @@
+package cookie_test
+
+import (
+	"gogo12306/cookie"
+	"gogo12306/logger"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"path/filepath"
+	"testing"
+)
+
+func TestPersistJar(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	jar, _ := cookiejar.New(nil)
+	u, _ := url.Parse("https://127.0.0.1:8443/otn/login/userLogin")
+	jar.SetCookies(u, []*http.Cookie{{Name: "tk", Value: "mocktk", Path: "/otn"}})
+
+	path := filepath.Join(t.TempDir(), "mock.session")
+	if err := cookie.SaveJar(jar, []string{"kyfw.12306.cn", "127.0.0.1:8443"}, path, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	jar2, _ := cookiejar.New(nil)
+	if err := cookie.LoadJar(jar2, path, "wrong key"); err == nil {
+		t.Error("load with wrong key should fail")
+	}
+
+	if err := cookie.LoadJar(jar2, path, "key"); err != nil {
+		t.Fatal(err)
+	}
+
+	u2, _ := url.Parse("https://kyfw.12306.cn/otn/confirmPassenger/initDc")
+	cookies := jar2.Cookies(u2)
+	if len(cookies) != 1 || cookies[0].Name != "tk" || cookies[0].Value != "mocktk" {
+		t.Errorf("unexpected cookies %v", cookies)
+	}
+}
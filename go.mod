@@ -4,6 +4,7 @@ go 1.17
 
 require (
 	github.com/tebeka/selenium v0.9.9
+	github.com/tjfoc/gmsm v1.4.1
 	go.uber.org/zap v1.19.1
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
 )
@@ -11,7 +12,6 @@ require (
 require (
 	github.com/BurntSushi/toml v0.4.1 // indirect
 	github.com/blang/semver v3.5.1+incompatible // indirect
-	github.com/tjfoc/gmsm v1.4.1 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
 	golang.org/x/net v0.0.0-20211216030914-fe4d6282115f // indirect
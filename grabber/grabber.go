@@ -0,0 +1,428 @@
+package grabber
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogo12306/cdn"
+	"gogo12306/clock"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/cookie"
+	"gogo12306/logger"
+	"gogo12306/login"
+	"gogo12306/session"
+	"gogo12306/ticket"
+	"gogo12306/worker"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Grabber 抢票程序，管理所有账号的会话和刷票任务，支持重新加载配置
+type Grabber struct {
+	cfgPath string
+
+	mu       sync.Mutex
+	cfg      *config.Config
+	pool     *cdn.Pool
+	stations *common.Stations
+	names    []string                    // 账号名，第一个为默认账号
+	sessions map[string]*session.Session // Key: 账号名
+	tasks    map[string]*worker.Task     // Key: 账号名、用户名和任务配置的 JSON
+
+	modTime time.Time // 配置文件的修改时间
+}
+
+func New(cfgPath string, cfg *config.Config) *Grabber {
+	return &Grabber{
+		cfgPath:  cfgPath,
+		cfg:      cfg,
+		pool:     cdn.NewPool(),
+		stations: common.NewStations(),
+		sessions: make(map[string]*session.Session),
+		tasks:    make(map[string]*worker.Task),
+	}
+}
+
+// Start 初始化站点信息，登录所有账号并开始刷票任务
+func (g *Grabber) Start() (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if problems := g.cfg.Validate(); len(problems) > 0 {
+		for _, p := range problems {
+			logger.Error("配置错误", zap.String("path", p.Path), zap.String("错误", p.Message))
+		}
+
+		return problems
+	}
+
+	if info, err := os.Stat(g.cfgPath); err == nil {
+		g.modTime = info.ModTime()
+	}
+
+	g.pool.SetEndpoint(g.cfg.Server.Host, g.cfg.Server.WWWHost)
+	if err = g.pool.LoadCDN(g.cfg.CDN.GoodCDNPath); err != nil {
+		return
+	}
+
+	var sessions map[string]*session.Session
+	if g.names, sessions, err = g.openSessions(g.cfg); err != nil {
+		return
+	}
+
+	for name, sess := range sessions {
+		g.sessions[name] = sess
+	}
+
+	if ok, precision := clock.Synced(); ok {
+		logger.Info("已同步服务器时间",
+			zap.String("服务器时间", clock.Now().Format("2006-01-02 15:04:05.000")),
+			zap.Duration("时间差", clock.Offset()),
+			zap.Duration("误差", precision),
+		)
+	}
+
+	var tasks map[string]*worker.Task
+	if tasks, err = g.parseTasks(g.cfg); err != nil {
+		return
+	}
+
+	for key, task := range tasks {
+		g.tasks[key] = task
+		worker.DoTask(task)
+	}
+
+	return
+}
+
+// openSessions 为配置中还没有会话或登录配置改变了的账号创建会话并登录，其他账号直接使用原会话
+func (g *Grabber) openSessions(cfg *config.Config) (names []string, sessions map[string]*session.Session, err error) {
+	names, logins := cfg.AccountLogins()
+	sessions = make(map[string]*session.Session)
+
+	// 新创建的会话出错时需要关闭
+	defer func() {
+		if err != nil {
+			for name, sess := range sessions {
+				if g.sessions[name] != sess {
+					sess.Close()
+				}
+			}
+		}
+	}()
+
+	for _, name := range names {
+		// 登录配置（用户名、密码、登录方式、验证码等）没有改变的账号继续使用原来的会话，不用重新登录
+		if sess := g.sessions[name]; sess != nil && reflect.DeepEqual(sess.Login, logins[name]) {
+			sessions[name] = sess
+			continue
+		}
+
+		// 会话使用登录配置的副本，重新加载时才能和新的登录配置比较
+		loginCfg := *logins[name]
+
+		var sess *session.Session
+		if sess, err = OpenSession(cfg, name, &loginCfg, g.pool, g.stations); err != nil {
+			return
+		}
+
+		sessions[name] = sess
+
+		if CanLogin(sess.Login) {
+			login.CheckLoginTimer(sess)
+		}
+	}
+
+	return
+}
+
+// CanLogin 是否配置了登录方式（用户名密码或扫码登录）
+func CanLogin(loginCfg *config.LoginConfig) bool {
+	return (loginCfg.Username != "" && loginCfg.Password != "") || loginCfg.LoginMethod == login.LoginMethodQR
+}
+
+// OpenSession 创建账号的会话，获取站点信息和余票查询地址，配置了登录方式时登录账号
+// stations 为空时获取站点列表，多个会话可以共用 pool 和 stations
+func OpenSession(cfg *config.Config, name string, loginCfg *config.LoginConfig,
+	pool *cdn.Pool, stations *common.Stations) (sess *session.Session, err error) {
+	if sess, err = session.New(cfg, loginCfg, pool, stations); err != nil {
+		return
+	}
+
+	sess.Name = name
+
+	// 出错时关闭会话
+	defer func() {
+		if err != nil {
+			sess.Close()
+		}
+	}()
+
+	// 站点信息所有会话共用，只需要获取一次
+	if stations.Len() == 0 {
+		if err = ticket.InitStations(sess); err != nil {
+			return
+		}
+	}
+
+	if err = ticket.InitLeftTickerURL(sess); err != nil {
+		return
+	}
+
+	if err = cookie.SetCookie(sess.Jar,
+		sess.Login.GetCookieMethod,
+		sess.Login.ChromeBrowserPath,
+		sess.Login.ChromeDriverPath,
+		sess.Login.RailExpiration,
+		sess.Login.RailDeviceID,
+	); err != nil {
+		return
+	}
+
+	// 先登录，好处时后面购票时不用再花时间登录，抢到票的几率增大
+	// 但也有可能遇到当余票足够准备下单时，系统已自动退出登录，还是需要重新登录
+	if CanLogin(sess.Login) {
+		logger.Info("登录账号", zap.String("账号", name), zap.String("用户名", sess.Login.Username))
+
+		if err = login.ResumeLogin(sess); err != nil {
+			return
+		}
+	} else if e := login.SyncConf(sess); e != nil {
+		// 不登录时也需要获取预售天数并同步服务器时间，失败时使用默认值
+		logger.Warn("获取预售天数和服务器时间失败", zap.String("账号", name), zap.Error(e))
+	}
+
+	return
+}
+
+// parseTasks 转换所有任务配置，任何一个任务出错都返回错误
+func (g *Grabber) parseTasks(cfg *config.Config) (tasks map[string]*worker.Task, err error) {
+	tasks = make(map[string]*worker.Task)
+	names, _ := cfg.AccountLogins()
+
+	for i := range cfg.Tasks {
+		taskCfg := &cfg.Tasks[i]
+
+		// 未指定账号时使用第一个账号
+		name := taskCfg.Account
+		if name == "" {
+			name = names[0]
+		}
+
+		sess := g.sessions[name]
+		if sess == nil {
+			logger.Error("任务使用的账号不存在", zap.String("账号", name), zap.Any("任务配置", taskCfg))
+
+			return nil, errors.New("account not found")
+		}
+
+		if problems := ticket.ValidateTask(sess, fmt.Sprintf("tasks[%d]", i), taskCfg); len(problems) > 0 {
+			for _, p := range problems {
+				logger.Error("配置错误", zap.String("path", p.Path), zap.String("错误", p.Message))
+			}
+
+			return nil, problems
+		}
+
+		// 账号的登录配置改变时会使用新的会话，任务需要重新转换，因此 Key 包含账号名和登录配置
+		var loginKey, key []byte
+		if loginKey, err = json.Marshal(sess.Login); err != nil {
+			return
+		}
+
+		if key, err = json.Marshal(taskCfg); err != nil {
+			return
+		}
+		key = append([]byte(name+"\x00"+string(loginKey)+"\x00"), key...)
+
+		if _, ok := tasks[string(key)]; ok {
+			logger.Error("任务配置重复", zap.Int("任务序号", i), zap.Any("任务配置", taskCfg))
+
+			return nil, errors.New("duplicate task")
+		}
+
+		var task *worker.Task
+		if task, err = ticket.ParseTask(sess, taskCfg); err != nil || task == nil {
+			logger.Error("转换任务配置出现错误", zap.Int("任务序号", i), zap.Any("任务配置", taskCfg), zap.Error(err))
+
+			return nil, errors.New("parse task error")
+		}
+
+		tasks[string(key)] = task
+	}
+
+	return
+}
+
+// Reload 重新读取配置文件，校验通过后增加、删除或更新刷票任务，登录配置没有改变的账号不会重新登录
+// 日志、CDN、服务器地址等配置需要重启程序才能生效
+func (g *Grabber) Reload() (err error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if info, err := os.Stat(g.cfgPath); err == nil {
+		g.modTime = info.ModTime()
+	}
+
+	var cfg *config.Config
+	if cfg, err = config.Load(g.cfgPath); err != nil {
+		logger.Error("重新加载配置文件错误，继续使用原配置", zap.String("path", g.cfgPath), zap.Error(err))
+
+		return
+	}
+
+	if problems := cfg.Validate(); len(problems) > 0 {
+		for _, p := range problems {
+			logger.Error("配置错误", zap.String("path", p.Path), zap.String("错误", p.Message))
+		}
+
+		logger.Error("新配置校验不通过，继续使用原配置", zap.String("path", g.cfgPath))
+
+		return problems
+	}
+
+	if !reflect.DeepEqual(cfg.Logger, g.cfg.Logger) ||
+		!reflect.DeepEqual(cfg.CDN, g.cfg.CDN) ||
+		!reflect.DeepEqual(cfg.Server, g.cfg.Server) {
+		logger.Warn("日志、CDN、服务器地址配置的修改需要重启程序才能生效")
+	}
+
+	// 新增的账号先登录
+	var (
+		names    []string
+		sessions map[string]*session.Session
+	)
+	if names, sessions, err = g.openSessions(cfg); err != nil {
+		logger.Error("新配置的账号登录失败，继续使用原配置", zap.Error(err))
+
+		return
+	}
+
+	oldSessions := g.sessions
+	g.sessions = sessions
+
+	// 先转换所有任务，全部成功后再替换正在运行的任务
+	var tasks map[string]*worker.Task
+	if tasks, err = g.parseTasks(cfg); err != nil {
+		for name, sess := range sessions {
+			if oldSessions[name] != sess {
+				sess.Close()
+			}
+		}
+		g.sessions = oldSessions
+
+		logger.Error("新配置的任务有错误，继续使用原配置", zap.Error(err))
+
+		return
+	}
+
+	var added, removed, kept int
+	for key, task := range g.tasks {
+		if _, ok := tasks[key]; ok {
+			continue
+		}
+
+		task.Stop()
+		delete(g.tasks, key)
+		removed++
+	}
+
+	for key, task := range tasks {
+		if _, ok := g.tasks[key]; ok {
+			kept++
+			continue
+		}
+
+		g.tasks[key] = task
+		worker.DoTask(task)
+		added++
+	}
+
+	// 删除不再使用的账号会话
+	for name, sess := range oldSessions {
+		if sessions[name] != sess {
+			login.SaveSession(sess)
+			sess.Close()
+		}
+	}
+
+	g.cfg = cfg
+	g.names = names
+
+	logger.Info("配置已重新加载",
+		zap.Int("新增任务", added),
+		zap.Int("删除任务", removed),
+		zap.Int("保留任务", kept),
+		zap.Strings("账号", names),
+	)
+
+	return
+}
+
+// Watch 定时检查配置文件是否被修改，修改后自动重新加载，done 关闭后停止检查
+func (g *Grabber) Watch(interval time.Duration, done <-chan struct{}) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+
+			case <-t.C:
+				info, err := os.Stat(g.cfgPath)
+				if err != nil {
+					continue
+				}
+
+				g.mu.Lock()
+				changed := !info.ModTime().Equal(g.modTime)
+				g.mu.Unlock()
+
+				if changed {
+					logger.Info("配置文件已修改，重新加载", zap.String("path", g.cfgPath))
+
+					g.Reload()
+				}
+			}
+		}
+	}()
+}
+
+// TaskCount 正在运行的刷票任务数量
+func (g *Grabber) TaskCount() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return len(g.tasks)
+}
+
+// Session 账号正在使用的会话
+func (g *Grabber) Session(name string) *session.Session {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return g.sessions[name]
+}
+
+// Stop 停止所有刷票任务并保存登录会话
+func (g *Grabber) Stop() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	for _, task := range g.tasks {
+		task.Stop()
+	}
+
+	// 保存登录会话，下次启动时不用重新登录
+	for _, name := range g.names {
+		login.SaveSession(g.sessions[name])
+		g.sessions[name].Close()
+	}
+}
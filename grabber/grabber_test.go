@@ -0,0 +1,203 @@
+package grabber_test
+
+import (
+	"encoding/json"
+	"gogo12306/config"
+	"gogo12306/grabber"
+	"gogo12306/logger"
+	"gogo12306/mock"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestReload(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	date := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	task := config.TaskConfig{
+		QueryOnly:      true,
+		OrderType:      1,
+		From:           "广州南",
+		To:             "上海虹桥",
+		StartDates:     []string{date},
+		TrainCodes:     []string{"D933"},
+		Seats:          []string{"二等座"},
+		ChooseSeats:    []string{"1A"},
+		SeatDetailType: []string{"0", "0", "0"},
+		Passengers:     []string{"张三"},
+	}
+
+	cfg := &config.Config{
+		Server: config.ServerConfig{Host: host, WWWHost: host},
+		Login:  config.LoginConfig{GetCookieMethod: 3, Username: "mock", Password: "mock"},
+		Tasks:  []config.TaskConfig{task},
+	}
+
+	cfgPath := filepath.Join(t.TempDir(), "config.json")
+	save := func() {
+		data, _ := json.Marshal(cfg)
+		if err := ioutil.WriteFile(cfgPath, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	save()
+
+	g := grabber.New(cfgPath, cfg)
+	if err := g.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer g.Stop()
+
+	if n := g.TaskCount(); n != 1 {
+		t.Fatalf("task count %d, want 1", n)
+	}
+
+	// 新增任务，原任务保留
+	task2 := task
+	task2.TrainCodes = []string{"G1314"}
+	cfg.Tasks = append(cfg.Tasks, task2)
+	save()
+
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := g.TaskCount(); n != 2 {
+		t.Fatalf("task count %d, want 2", n)
+	}
+
+	// 重复的任务不能加载
+	cfg.Tasks = append(cfg.Tasks, task)
+	save()
+
+	if err := g.Reload(); err == nil {
+		t.Error("reload with duplicate tasks should fail")
+	}
+	cfg.Tasks = cfg.Tasks[:2]
+
+	if n := g.TaskCount(); n != 2 {
+		t.Fatalf("task count %d, want 2", n)
+	}
+
+	// 用户名改变后使用新的会话，任务重新转换
+	cfg.Login.Username = "mock2"
+	save()
+
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := g.TaskCount(); n != 2 {
+		t.Fatalf("task count %d, want 2", n)
+	}
+
+	// 用户名不变，其他登录配置改变后也使用新的会话
+	sess := g.Session("")
+	cfg.Login.CaptchaRetries = 5
+	save()
+
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Session("") == sess || g.Session("").Login.CaptchaRetries != 5 {
+		t.Error("session not reopened after login config changed")
+	}
+
+	if n := g.TaskCount(); n != 2 {
+		t.Fatalf("task count %d, want 2", n)
+	}
+
+	// 登录配置没有改变时继续使用原会话
+	sess = g.Session("")
+	save()
+
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if g.Session("") != sess {
+		t.Error("session reopened without login config change")
+	}
+
+	// 错误的配置不会替换正在运行的任务
+	bad := task
+	bad.StartDates = []string{"2022/01/01"}
+	cfg.Tasks = []config.TaskConfig{bad}
+	save()
+
+	if err := g.Reload(); err == nil {
+		t.Error("reload with invalid start_dates should fail")
+	}
+
+	if n := g.TaskCount(); n != 2 {
+		t.Fatalf("task count %d, want 2", n)
+	}
+
+	// 删除所有任务
+	cfg.Tasks = nil
+	save()
+
+	if err := g.Reload(); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := g.TaskCount(); n != 0 {
+		t.Fatalf("task count %d, want 0", n)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	cfg := &config.Config{
+		Server: config.ServerConfig{Host: host, WWWHost: host},
+		Login:  config.LoginConfig{GetCookieMethod: 3, Username: "mock", Password: "mock"},
+		Tasks: []config.TaskConfig{{
+			OrderType:         1,
+			AllowCandidate:    true,
+			CandidateDeadline: 30,
+			From:              "广州西",
+			To:                "上海虹桥",
+			StartDates:        []string{"2022-01-01", "2022/01/02"},
+			Seats:             []string{"一等座", "头等座"},
+			ChooseSeats:       []string{"1B", "3A"},
+			SeatDetailType:    []string{"0", "0", "2"},
+			Passengers:        []string{"张三", "赵六"},
+		}},
+	}
+
+	paths := make(map[string]bool)
+	for _, p := range grabber.Validate(cfg) {
+		paths[p.Path] = true
+	}
+
+	for _, path := range []string{
+		"tasks[0].from",
+		"tasks[0].start_dates[1]",
+		"tasks[0].seats[1]",
+		"tasks[0].choose_seats[0]",
+		"tasks[0].choose_seats[1]",
+		"tasks[0].seat_detail_type[2]",
+		"tasks[0].candidate_deadline",
+		"tasks[0].passengers[1]",
+	} {
+		if !paths[path] {
+			t.Errorf("missing problem at %s", path)
+		}
+	}
+
+	if len(paths) != 8 {
+		t.Errorf("unexpected problems %v", paths)
+	}
+}
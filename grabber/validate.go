@@ -0,0 +1,96 @@
+package grabber
+
+import (
+	"fmt"
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/cookie"
+	"gogo12306/logger"
+	"gogo12306/login"
+	"gogo12306/session"
+	"gogo12306/ticket"
+
+	"go.uber.org/zap"
+)
+
+// Validate 检查整个配置并返回所有问题，会联网获取站点列表，并登录账号获取联系人列表检查乘车人
+func Validate(cfg *config.Config) (problems config.Problems) {
+	problems = cfg.Validate()
+
+	pool := cdn.NewPool()
+	pool.SetEndpoint(cfg.Server.Host, cfg.Server.WWWHost)
+	pool.LoadCDN(cfg.CDN.GoodCDNPath)
+
+	var (
+		stations = common.NewStations()
+		sessions = make(map[string]*session.Session)
+	)
+	names, logins := cfg.AccountLogins()
+	for i, name := range names {
+		path := "login"
+		if len(cfg.Accounts) > 0 {
+			path = fmt.Sprintf("accounts[%d]", i)
+		}
+
+		sess, err := session.New(cfg, logins[name], pool, stations)
+		if err != nil {
+			problems.Add(path, "创建会话失败: %s", err.Error())
+			continue
+		}
+
+		sess.Name = name
+		sessions[name] = sess
+		defer sess.Close()
+
+		if stations.Len() == 0 {
+			if err = ticket.InitStations(sess); err != nil {
+				logger.Warn("获取站点列表失败，不检查站名", zap.Error(err))
+			}
+		}
+
+		if !CanLogin(sess.Login) {
+			logger.Info("账号没有配置用户名密码，不检查乘车人", zap.String("账号", name))
+			continue
+		}
+
+		if err = cookie.SetCookie(sess.Jar,
+			sess.Login.GetCookieMethod,
+			sess.Login.ChromeBrowserPath,
+			sess.Login.ChromeDriverPath,
+			sess.Login.RailExpiration,
+			sess.Login.RailDeviceID,
+		); err != nil {
+			problems.Add(path, "设置 RAIL_EXPIRATION/RAIL_DEVICEID 失败: %s", err.Error())
+			continue
+		}
+
+		if err = ticket.InitLeftTickerURL(sess); err != nil {
+			logger.Warn("获取余票查询地址失败", zap.Error(err))
+		}
+
+		if err = login.ResumeLogin(sess); err != nil {
+			problems.Add(path, "登录失败，无法检查乘车人: %s", err.Error())
+			continue
+		}
+
+		// 保存登录会话，开始抢票时不用再登录
+		login.SaveSession(sess)
+	}
+
+	for i := range cfg.Tasks {
+		taskCfg := &cfg.Tasks[i]
+
+		name := taskCfg.Account
+		if name == "" {
+			name = names[0]
+		}
+
+		// 账号不存在的问题 cfg.Validate 已经检查过
+		if sess := sessions[name]; sess != nil {
+			problems = append(problems, ticket.ValidateTask(sess, fmt.Sprintf("tasks[%d]", i), taskCfg)...)
+		}
+	}
+
+	return
+}
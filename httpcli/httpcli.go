@@ -3,6 +3,7 @@ package httpcli
 import (
 	"compress/gzip"
 	"crypto/tls"
+	"gogo12306/clock"
 	"gogo12306/logger"
 	"io/ioutil"
 	"net/http"
@@ -87,6 +88,13 @@ func DoHttp(req *http.Request, jar *cookiejar.Jar) (body []byte, statusCode int,
 		j.SetCookies(u, res.Cookies())
 	}
 
+	// 用 12306 服务器响应的 Date 头同步服务器时间
+	if res != nil && strings.HasSuffix(req.Host, "12306.cn") {
+		if date, e := http.ParseTime(res.Header.Get("Date")); e == nil {
+			clock.Observe(date, time.Second, t0, t0.Add(duration))
+		}
+	}
+
 	if err != nil {
 		// logger.Error("HttpDo err",
 		// 	zap.String("method", string(req.Header.Method())),
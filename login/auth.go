@@ -4,21 +4,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
-	"net/http/cookiejar"
 
 	"go.uber.org/zap"
 )
 
-func Auth(jar *cookiejar.Jar) (tk string, err error) {
+func Auth(sess *session.Session) (tk string, err error) {
 	const (
 		url1     = "https://%s/otn/resources/login.html"
 		referer1 = "https://kyfw.12306.cn/otn/view/index.html"
 	)
-	req1, _ := http.NewRequest("GET", fmt.Sprintf(url1, cdn.GetCDN()), nil)
+	req1, _ := http.NewRequest("GET", fmt.Sprintf(url1, sess.CDN.GetCDN()), nil)
 	req1.Header.Set("Referer", referer1)
 	httpcli.DefaultHeaders(req1)
 
@@ -26,7 +25,7 @@ func Auth(jar *cookiejar.Jar) (tk string, err error) {
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req1, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req1, sess.Jar); err != nil {
 		logger.Error("授权登录错误", zap.Error(err))
 
 		return "", err
@@ -40,11 +39,11 @@ func Auth(jar *cookiejar.Jar) (tk string, err error) {
 		url2     = "https://%s/passport/web/auth/uamtk-static?appid=otn"
 		referer2 = "https://kyfw.12306.cn/otn/resources/login.html"
 	)
-	req2, _ := http.NewRequest("GET", fmt.Sprintf(url2, cdn.GetCDN()), nil)
+	req2, _ := http.NewRequest("GET", fmt.Sprintf(url2, sess.CDN.GetCDN()), nil)
 	req2.Header.Set("Referer", referer2)
 	httpcli.DefaultHeaders(req2)
 
-	if body, statusCode, err = httpcli.DoHttp(req2, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req2, sess.Jar); err != nil {
 		logger.Error("授权错误", zap.Error(err))
 
 		return "", err
@@ -5,11 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 	"time"
@@ -17,7 +16,7 @@ import (
 	"go.uber.org/zap"
 )
 
-func CheckLoginStatus(jar *cookiejar.Jar) (logined bool, messages string, err error) {
+func CheckLoginStatus(sess *session.Session) (logined bool, messages string, err error) {
 	const (
 		url0    = "https://%s/otn/login/checkUser"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -27,7 +26,7 @@ func CheckLoginStatus(jar *cookiejar.Jar) (logined bool, messages string, err er
 	payload.Add("_json_att", "")
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -35,7 +34,7 @@ func CheckLoginStatus(jar *cookiejar.Jar) (logined bool, messages string, err er
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("检查登录状态错误", zap.Error(err))
 
 		return false, "", err
@@ -66,19 +65,19 @@ func CheckLoginStatus(jar *cookiejar.Jar) (logined bool, messages string, err er
 	return result.Data.Flag, strings.Join(result.Messages, ","), nil
 }
 
-func CheckAndRelogin(jar *cookiejar.Jar) (err error) {
+func CheckAndRelogin(sess *session.Session) (err error) {
 	var (
 		logined  bool
 		messages string
 	)
-	if logined, messages, err = CheckLoginStatus(jar); err != nil {
+	if logined, messages, err = CheckLoginStatus(sess); err != nil {
 		return
 	}
 
 	if !logined {
 		logger.Warn("用户已离线，尝试重新登录...", zap.String("错误提示", messages))
 
-		if err = Login(jar); err != nil {
+		if err = Login(sess); err != nil {
 			return
 		}
 	}
@@ -86,11 +85,19 @@ func CheckAndRelogin(jar *cookiejar.Jar) (err error) {
 	return
 }
 
-func CheckLoginTimer(jar *cookiejar.Jar) {
+func CheckLoginTimer(sess *session.Session) {
 	go func() {
 		t := time.NewTicker(time.Second * 60) // 检查时间间隔不要太短
-		for range t.C {
-			CheckAndRelogin(jar)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-sess.Done(): // 会话已关闭
+				return
+
+			case <-t.C:
+				CheckAndRelogin(sess)
+			}
 		}
 	}()
 }
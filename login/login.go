@@ -7,20 +7,19 @@ import (
 	"errors"
 	"fmt"
 	"gogo12306/captcha"
-	"gogo12306/cdn"
 	"gogo12306/common"
-	"gogo12306/config"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 
 	"github.com/tjfoc/gmsm/sm4"
 	"go.uber.org/zap"
 )
 
-func DoLogin(jar *cookiejar.Jar, username, password, answer string) (err error) {
+// DoLogin 统一认证登录，answer 为图片验证码坐标，randCode 为短信验证码，不需要时留空
+func DoLogin(sess *session.Session, username, password, answer, randCode string) (err error) {
 	// https://kyfw.12306.cn/otn/resources/merged/queryLeftTicket_end_js.js 关键词: popup_loginForUam 函数
 
 	const (
@@ -40,9 +39,13 @@ func DoLogin(jar *cookiejar.Jar, username, password, answer string) (err error)
 	payload.Add("password", "@"+base64.StdEncoding.EncodeToString(encPwd))
 	payload.Add("appid", "otn")
 	payload.Add("answer", answer)
+	if randCode != "" {
+		payload.Add("randCode", randCode)
+		payload.Add("checkMode", "0") // 0 - 短信验证码校验
+	}
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -50,7 +53,7 @@ func DoLogin(jar *cookiejar.Jar, username, password, answer string) (err error)
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("登录请求错误", zap.Error(err))
 
 		return err
@@ -83,7 +86,7 @@ func DoLogin(jar *cookiejar.Jar, username, password, answer string) (err error)
 	return
 }
 
-func DoLoginWithoutCaptcha(jar *cookiejar.Jar, username, password string) (err error) {
+func DoLoginWithoutCaptcha(sess *session.Session, username, password string) (err error) {
 	const (
 		url0    = "https://%s/otn/login/loginAysnSuggest"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -101,7 +104,7 @@ func DoLoginWithoutCaptcha(jar *cookiejar.Jar, username, password string) (err e
 	payload.Add("userDTO.password", "@"+base64.StdEncoding.EncodeToString(encPwd))
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -109,7 +112,7 @@ func DoLoginWithoutCaptcha(jar *cookiejar.Jar, username, password string) (err e
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("无验证码登录请求错误", zap.Error(err))
 
 		return err
@@ -228,11 +231,11 @@ if res.loginCheck == 'Y' {
 (后面继续补充...)
 
 */
-func Login(jar *cookiejar.Jar) (err error) {
+func Login(sess *session.Session) (err error) {
 	common.CheckOperationPeriod()
 
 	var conf *LoginConfResult
-	if conf, err = loginConf(jar); err != nil {
+	if conf, err = loginConf(sess); err != nil {
 		return
 	}
 
@@ -241,60 +244,92 @@ func Login(jar *cookiejar.Jar) (err error) {
 		return
 	}
 
-	if conf.IsUAMLogin { // 需要验证码登录
-		var (
-			base64Img string
-			pass      bool
-		)
-		// 获取验证码图像
-		if base64Img, err = captcha.GetCaptcha(jar); err != nil {
-			return
-		}
+	if sess.Login.LoginMethod == LoginMethodQR { // 扫二维码登录
+		if !conf.IsSweepLogin {
+			logger.Error("12306 暂时关闭了扫码登录，请使用用户名密码登录", zap.String("账号", sess.Name))
 
-		// 自动识别验证码并获取结果
-		var (
-			result []int
-			answer string
-		)
-		if _, answer, err = captcha.GetCaptchaResult(jar, config.Cfg.Login.OCRUrl, base64Img); err != nil {
-			return
+			return errors.New("sweep login is disabled")
 		}
 
-		if answer == "" {
-			logger.Error("ConvertCaptchaResult 转换坐标失败", zap.Ints("result", result))
+		if err = QRLogin(sess); err != nil {
 			return
 		}
-
-		// 校验验证码
-		if pass, err = captcha.VerifyCaptcha(jar, answer); err != nil || !pass {
+	} else if conf.IsUAMLogin || conf.IsLoginPassCode { // 统一认证登录
+		// 先检查需要哪种校验方式
+		var checkCode string
+		if checkCode, err = CheckLoginVerify(sess); err != nil {
 			return
 		}
 
-		// 登录
-		if err = DoLogin(jar, config.Cfg.Login.Username, config.Cfg.Login.Password, answer); err != nil {
-			return
-		}
+		switch checkCode {
+		case loginCheckNone:
+			if err = DoLogin(sess, sess.Login.Username, sess.Login.Password, "", ""); err != nil {
+				return
+			}
 
-		// 授权
-		var tk string
-		if tk, err = Auth(jar); err != nil {
-			return
-		}
+			if err = authAndGetUserInfo(sess); err != nil {
+				return
+			}
 
-		// 获取用户信息
-		if err = GetUserInfo(jar, tk); err != nil {
-			return
+		case loginCheckSMS:
+			if !conf.IsMessagePassCode {
+				logger.Error("12306 要求短信验证但暂时关闭了短信验证码登录，请稍后再试或使用扫码登录", zap.String("账号", sess.Name))
+
+				return errors.New("message pass code is disabled")
+			}
+
+			if err = SMSLogin(sess); err != nil {
+				return
+			}
+
+		default: // 其他校验方式使用图片验证码
+			if err = captchaLogin(sess); err != nil {
+				return
+			}
 		}
 	} else { // 无需验证码登录
-		if err = DoLoginWithoutCaptcha(jar, config.Cfg.Login.Username, config.Cfg.Login.Password); err != nil {
+		if err = DoLoginWithoutCaptcha(sess, sess.Login.Username, sess.Login.Password); err != nil {
 			return
 		}
 	}
 
 	// 获取乘客列表
-	if err = GetPassengerList(jar); err != nil {
+	if err = GetPassengerList(sess); err != nil {
 		return
 	}
 
 	return
 }
+
+// captchaLogin 图片验证码登录
+func captchaLogin(sess *session.Session) (err error) {
+	var solver captcha.Solver
+	if solver, err = captcha.NewSolver(sess.Login); err != nil {
+		return
+	}
+
+	// 获取、识别并校验验证码
+	var answer string
+	if answer, err = captcha.SolveLogin(sess, solver); err != nil {
+		return
+	}
+
+	// 登录
+	if err = DoLogin(sess, sess.Login.Username, sess.Login.Password, answer, ""); err != nil {
+		return
+	}
+
+	return authAndGetUserInfo(sess)
+}
+
+// authAndGetUserInfo 统一认证登录成功后授权并获取用户信息
+func authAndGetUserInfo(sess *session.Session) (err error) {
+	// 授权
+	var tk string
+	if tk, err = Auth(sess); err != nil {
+		return
+	}
+
+	// 获取用户信息
+	return GetUserInfo(sess, tk)
+}
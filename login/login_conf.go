@@ -5,13 +5,13 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
-	"gogo12306/config"
+	"gogo12306/clock"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -32,15 +32,22 @@ type LoginConfResult struct {
 	OtherControl   int `json:"other_control"` // 其他票提前开售天数
 }
 
+// SyncConf 获取登录设置，更新预售天数并同步服务器时间，不需要登录
+func SyncConf(sess *session.Session) (err error) {
+	_, err = loginConf(sess)
+
+	return
+}
+
 // loginConf 获取登录设置
-func loginConf(jar *cookiejar.Jar) (info *LoginConfResult, err error) {
+func loginConf(sess *session.Session) (info *LoginConfResult, err error) {
 	const (
 		url0    = "https://%s/otn/login/conf"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
 	)
 
 	buf := bytes.NewBuffer([]byte("{}"))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
@@ -48,8 +55,11 @@ func loginConf(jar *cookiejar.Jar) (info *LoginConfResult, err error) {
 	var (
 		body       []byte
 		statusCode int
+		t0         = time.Now()
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
+	t1 := time.Now()
+	if err != nil {
 		logger.Error("获取登录设置错误", zap.Error(err))
 
 		return
@@ -109,8 +119,18 @@ func loginConf(jar *cookiejar.Jar) (info *LoginConfResult, err error) {
 	}
 
 	// 预售天数
-	config.Cfg.StudentPresellDays = response.Data.StudentControl
-	config.Cfg.OtherPresellDays = response.Data.OtherControl
+	sess.SetPresellDays(response.Data.StudentControl, response.Data.OtherControl)
+
+	// 同步服务器时间，now 为毫秒时间戳
+	if response.Data.Now > 0 {
+		clock.Observe(time.UnixMilli(response.Data.Now), time.Millisecond, t0, t1)
+
+		_, precision := clock.Synced()
+		logger.Debug("同步服务器时间",
+			zap.Duration("时间差", clock.Offset()),
+			zap.Duration("误差", precision),
+		)
+	}
 
 	return
 }
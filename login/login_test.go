@@ -2,10 +2,13 @@ package login_test
 
 import (
 	"gogo12306/captcha"
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
 	"gogo12306/cookie"
 	"gogo12306/logger"
 	"gogo12306/login"
-	"net/http/cookiejar"
+	"gogo12306/session"
 	"testing"
 )
 
@@ -33,15 +36,19 @@ func TestLogin(t *testing.T) {
 	logger.Init(true, "test.log", "info", 1024, 7)
 
 	var (
-		err error
-		jar *cookiejar.Jar
+		err  error
+		sess *session.Session
 	)
-	if jar, err = cookiejar.New(nil); err != nil {
+	if sess, err = session.New(&config.Config{}, &config.LoginConfig{
+		Username: USERNAME,
+		Password: PASSWORD,
+		OCRUrl:   OCRURL,
+	}, cdn.NewPool(), common.NewStations()); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
-	if err = cookie.SetCookie(jar, GetCookieMethod, ChromeBrowserPath, ChromeDriverPath, RailExpiration, RailDeviceID); err != nil {
+	if err = cookie.SetCookie(sess.Jar, GetCookieMethod, ChromeBrowserPath, ChromeDriverPath, RailExpiration, RailDeviceID); err != nil {
 		t.Error(err.Error())
 		return
 	}
@@ -51,44 +58,44 @@ func TestLogin(t *testing.T) {
 		pass      bool
 	)
 	// 获取验证码图像
-	if base64Img, err = captcha.GetCaptcha(jar); err != nil {
+	if base64Img, err = captcha.GetCaptcha(sess); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 自动识别验证码并获取结果
 	var answer string
-	if _, answer, err = captcha.GetCaptchaResult(jar, OCRURL, base64Img); err != nil {
+	if answer, err = (&captcha.HTTPSolver{URL: OCRURL}).Solve(base64Img); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 校验验证码
-	if pass, err = captcha.VerifyCaptcha(jar, answer); err != nil || !pass {
+	if pass, err = captcha.VerifyCaptcha(sess, answer); err != nil || !pass {
 		t.Error(err.Error())
 		return
 	}
 
 	// 登录
-	if err = login.DoLogin(jar, USERNAME, PASSWORD, answer); err != nil {
+	if err = login.DoLogin(sess, USERNAME, PASSWORD, answer, ""); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 授权并获取用户信息
 	var newapptk string
-	if newapptk, err = login.Auth(jar); err != nil {
+	if newapptk, err = login.Auth(sess); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
-	if err = login.GetUserInfo(jar, newapptk); err != nil {
+	if err = login.GetUserInfo(sess, newapptk); err != nil {
 		t.Error(err.Error())
 		return
 	}
 
 	// 获取乘客列表
-	if err = login.GetPassengerList(jar); err != nil {
+	if err = login.GetPassengerList(sess); err != nil {
 		t.Error(err.Error())
 		return
 	}
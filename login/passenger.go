@@ -4,29 +4,22 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
 	"gogo12306/common"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
 
 	"go.uber.org/zap"
 )
 
-var passengers map[string]*common.PassengerInfo
-
-func init() {
-	passengers = make(map[string]*common.PassengerInfo)
-}
-
-func GetPassengerList(jar *cookiejar.Jar) (err error) {
+func GetPassengerList(sess *session.Session) (err error) {
 	const (
 		url     = "https://%s/otn/confirmPassenger/getPassengerDTOs"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
 	)
-	req, _ := http.NewRequest("GET", fmt.Sprintf(url, cdn.GetCDN()), nil)
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetCDN()), nil)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -34,7 +27,7 @@ func GetPassengerList(jar *cookiejar.Jar) (err error) {
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("获取乘客列表请求错误", zap.Error(err))
 
 		return err
@@ -68,30 +61,16 @@ func GetPassengerList(jar *cookiejar.Jar) (err error) {
 	)
 
 	if result.Data.NoLogin {
-		return Login(jar)
+		return Login(sess)
 	}
 
 	fmt.Println(strings.Repeat("-", 100))
 	fmt.Println("联系人列表，若有重名联系人，请在配置中使用 UUID 作为乘车人:")
 	for _, passenger := range result.Data.NormalPassengers {
-		passengers[passenger.UUID] = passenger
 		fmt.Println(passenger.String())
 	}
 	fmt.Println(strings.Repeat("-", 100))
 
+	sess.SetPassengers(result.Data.NormalPassengers)
 	return
 }
-
-func GetPassenger(passengerName string) *common.PassengerInfo {
-	for _, passengerInfo := range passengers {
-		if passengerInfo.PassengerName == passengerName {
-			return passengerInfo
-		}
-	}
-
-	return nil
-}
-
-func GetPassengerByUUID(uuid string) *common.PassengerInfo {
-	return passengers[uuid]
-}
@@ -0,0 +1,299 @@
+package login
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogo12306/config"
+	"gogo12306/httpcli"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"image"
+	"image/png"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	LoginMethodPassword = 0 // 用户名密码登录（按 12306 的登录设置决定是否需要验证码）
+	LoginMethodQR       = 1 // 扫二维码登录
+)
+
+// 二维码状态
+const (
+	qrStatusWaiting   = "0" // 等待扫码
+	qrStatusScanned   = "1" // 已扫码，等待确认
+	qrStatusConfirmed = "2" // 已确认登录
+	qrStatusExpired   = "3" // 二维码已过期
+)
+
+const (
+	qrPollInterval = time.Second
+	qrMaxRefresh   = 3 // 二维码过期后最多重新生成的次数
+)
+
+// CreateQR 生成登录二维码，返回二维码 PNG 图像和二维码 UUID
+func CreateQR(sess *session.Session) (img []byte, uuid string, err error) {
+	const (
+		url0    = "https://%s/passport/web/create-qr64"
+		referer = "https://kyfw.12306.cn/otn/resources/login.html"
+	)
+
+	payload := url.Values{}
+	payload.Add("appid", "otn")
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
+		logger.Error("生成登录二维码错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("生成登录二维码失败", zap.Int("statusCode", statusCode), zap.ByteString("res", body))
+
+		return nil, "", errors.New("create qr failure")
+	}
+
+	type CreateQRResult struct {
+		ResultCode    string `json:"result_code"`
+		ResultMessage string `json:"result_message"`
+		Image         string `json:"image"`
+		UUID          string `json:"uuid"`
+	}
+
+	result := CreateQRResult{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		logger.Error("解析登录二维码错误", zap.ByteString("res", body), zap.Error(err))
+
+		return
+	}
+
+	if result.ResultCode != "0" {
+		logger.Error("生成登录二维码失败", zap.String("code", result.ResultCode), zap.String("msg", result.ResultMessage))
+
+		return nil, "", errors.New("create qr failure")
+	}
+
+	if img, err = base64.StdEncoding.DecodeString(result.Image); err != nil {
+		logger.Error("解码登录二维码图像错误", zap.Error(err))
+
+		return
+	}
+
+	return img, result.UUID, nil
+}
+
+// CheckQR 查询二维码扫码状态
+func CheckQR(sess *session.Session, uuid string) (status string, err error) {
+	const (
+		url0    = "https://%s/passport/web/checkqr"
+		referer = "https://kyfw.12306.cn/otn/resources/login.html"
+	)
+
+	payload := url.Values{}
+	payload.Add("uuid", uuid)
+	payload.Add("appid", "otn")
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
+		logger.Error("查询二维码状态错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("查询二维码状态失败", zap.Int("statusCode", statusCode), zap.ByteString("res", body))
+
+		return "", errors.New("check qr failure")
+	}
+
+	type CheckQRResult struct {
+		ResultCode    string `json:"result_code"`
+		ResultMessage string `json:"result_message"`
+	}
+
+	result := CheckQRResult{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		logger.Error("解析二维码状态错误", zap.ByteString("res", body), zap.Error(err))
+
+		return
+	}
+
+	switch result.ResultCode {
+	case qrStatusWaiting, qrStatusScanned, qrStatusConfirmed, qrStatusExpired:
+		return result.ResultCode, nil
+
+	default:
+		logger.Error("查询二维码状态失败", zap.String("code", result.ResultCode), zap.String("msg", result.ResultMessage))
+
+		return "", errors.New("check qr failure")
+	}
+}
+
+// RenderQR 将二维码图像转换为可在终端显示的字符画，每个字符显示上下两个模块
+// 终端一般是深色背景，所以用字符块显示二维码的浅色部分
+func RenderQR(img image.Image) (s string, err error) {
+	isDark := func(x, y int) bool {
+		r, g, b, _ := img.At(x, y).RGBA()
+		return r+g+b < 0x7fff*3
+	}
+
+	// 左上角定位图案的第一个深色像素
+	bounds := img.Bounds()
+	left, top := -1, -1
+	for y := bounds.Min.Y; y < bounds.Max.Y && top < 0; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if isDark(x, y) {
+				left, top = x, y
+				break
+			}
+		}
+	}
+
+	if top < 0 {
+		return "", errors.New("qr image is blank")
+	}
+
+	// 定位图案宽 7 个模块，据此计算每个模块的像素数
+	run := 0
+	for x := left; x < bounds.Max.X && isDark(x, top); x++ {
+		run++
+	}
+
+	moduleSize := run / 7
+	if moduleSize == 0 {
+		return "", errors.New("qr image is too small")
+	}
+
+	size := (bounds.Max.X - 2*left + bounds.Min.X) / moduleSize
+	if size <= 0 {
+		return "", errors.New("qr image is invalid")
+	}
+
+	// 四周留出 2 个模块的空白方便识别
+	const quiet = 2
+	light := func(row, col int) bool {
+		if row < 0 || col < 0 || row >= size || col >= size {
+			return true
+		}
+
+		return !isDark(left+col*moduleSize+moduleSize/2, top+row*moduleSize+moduleSize/2)
+	}
+
+	var sb strings.Builder
+	for row := -quiet; row < size+quiet; row += 2 {
+		for col := -quiet; col < size+quiet; col++ {
+			upper, lower := light(row, col), light(row+1, col)
+			switch {
+			case upper && lower:
+				sb.WriteString("█")
+			case upper:
+				sb.WriteString("▀")
+			case lower:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+
+	return sb.String(), nil
+}
+
+// showQR 在终端显示二维码并保存为 PNG 图像
+func showQR(sess *session.Session, data []byte) {
+	imagePath := sess.Login.QRImagePath
+	if imagePath == "" {
+		imagePath = config.DefaultQRImagePath
+	}
+
+	if err := ioutil.WriteFile(imagePath, data, 0644); err != nil {
+		logger.Error("保存登录二维码图像错误", zap.String("path", imagePath), zap.Error(err))
+	} else {
+		logger.Info("登录二维码图像已保存", zap.String("path", imagePath))
+	}
+
+	img, err := png.Decode(bytes.NewReader(data))
+	if err != nil {
+		logger.Error("解析登录二维码图像错误", zap.Error(err))
+
+		return
+	}
+
+	var s string
+	if s, err = RenderQR(img); err != nil {
+		logger.Error("显示登录二维码错误，请打开二维码图像扫码", zap.String("path", imagePath), zap.Error(err))
+
+		return
+	}
+
+	fmt.Fprintf(os.Stdout, "\n请使用 12306 APP 扫描以下二维码登录（账号: %s）:\n%s\n", sess.Name, s)
+}
+
+// QRLogin 扫二维码登录
+func QRLogin(sess *session.Session) (err error) {
+	for refresh := 0; refresh <= qrMaxRefresh; refresh++ {
+		var (
+			img  []byte
+			uuid string
+		)
+		if img, uuid, err = CreateQR(sess); err != nil {
+			return
+		}
+
+		showQR(sess, img)
+
+		var status, lastStatus string
+		for status != qrStatusExpired {
+			time.Sleep(qrPollInterval)
+
+			if status, err = CheckQR(sess, uuid); err != nil {
+				return
+			}
+
+			if status == lastStatus {
+				continue
+			}
+
+			lastStatus = status
+			switch status {
+			case qrStatusScanned:
+				logger.Info("二维码已扫描，请在 12306 APP 上确认登录", zap.String("账号", sess.Name))
+
+			case qrStatusConfirmed:
+				logger.Info("扫码登录成功", zap.String("账号", sess.Name))
+
+				return authAndGetUserInfo(sess)
+
+			case qrStatusExpired:
+				logger.Warn("登录二维码已过期，重新生成", zap.String("账号", sess.Name))
+			}
+		}
+	}
+
+	logger.Error("登录二维码多次过期，扫码登录失败", zap.String("账号", sess.Name))
+
+	return errors.New("qr login timeout")
+}
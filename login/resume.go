@@ -0,0 +1,80 @@
+package login
+
+import (
+	"errors"
+	"gogo12306/cookie"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"os"
+	"path/filepath"
+
+	"go.uber.org/zap"
+)
+
+// sessionFile 登录会话文件路径，每个用户名一个文件，没有密钥时不保存
+func sessionFile(sess *session.Session) (path, key string) {
+	if sess.Login.SessionDir == "" || sess.Login.Username == "" {
+		return "", ""
+	}
+
+	key = sess.Login.SessionKey
+	if key == "" {
+		key = sess.Login.Password
+	}
+
+	if key == "" {
+		return "", ""
+	}
+
+	return filepath.Join(sess.Login.SessionDir, sess.Login.Username+".session"), key
+}
+
+// ResumeLogin 尝试恢复上次保存的登录会话，会话已失效或不存在时重新登录
+func ResumeLogin(sess *session.Session) (err error) {
+	path, key := sessionFile(sess)
+	if path == "" {
+		return Login(sess)
+	}
+
+	if err = cookie.LoadJar(sess.Jar, path, key); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			logger.Info("没有保存的登录会话，重新登录", zap.String("账号", sess.Name))
+		}
+
+		return Login(sess)
+	}
+
+	// 获取登录设置（预售天数等）
+	if _, err = loginConf(sess); err != nil {
+		return
+	}
+
+	var (
+		logined  bool
+		messages string
+	)
+	if logined, messages, err = CheckLoginStatus(sess); err != nil {
+		return
+	}
+
+	if !logined {
+		logger.Warn("保存的登录会话已失效，重新登录", zap.String("账号", sess.Name), zap.String("错误提示", messages))
+
+		return Login(sess)
+	}
+
+	logger.Info("已使用保存的登录会话登录", zap.String("账号", sess.Name))
+
+	// 获取乘客列表
+	return GetPassengerList(sess)
+}
+
+// SaveSession 保存登录会话，下次启动时可以直接使用
+func SaveSession(sess *session.Session) (err error) {
+	path, key := sessionFile(sess)
+	if path == "" {
+		return
+	}
+
+	return cookie.SaveJar(sess.Jar, sess.CDN.Hosts(), path, key)
+}
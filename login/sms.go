@@ -1,7 +1,223 @@
 package login
 
-import "net/http/cookiejar"
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogo12306/httpcli"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
 
-func GetMessageCode(jar *cookiejar.Jar) (err error) {
+	"go.uber.org/zap"
+)
+
+// 登录校验方式
+const (
+	loginCheckNone  = "0" // 无需校验
+	loginCheckSlide = "1" // 滑动验证
+	loginCheckSMS   = "3" // 短信验证码校验
+)
+
+// 短信验证码有效期
+const messageCodeTimeout = 5 * time.Minute
+
+// CheckLoginVerify 登录前检查需要哪种校验方式
+func CheckLoginVerify(sess *session.Session) (checkCode string, err error) {
+	const (
+		url0    = "https://%s/passport/web/checkLoginVerify"
+		referer = "https://kyfw.12306.cn/otn/resources/login.html"
+	)
+
+	payload := url.Values{}
+	payload.Add("username", sess.Login.Username)
+	payload.Add("appid", "otn")
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
+		logger.Error("检查登录校验方式错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("检查登录校验方式失败", zap.Int("statusCode", statusCode), zap.ByteString("res", body))
+
+		return "", errors.New("check login verify failure")
+	}
+
+	type CheckLoginVerifyResult struct {
+		ResultCode     int    `json:"result_code"`
+		ResultMessage  string `json:"result_message"`
+		LoginCheckCode string `json:"login_check_code"`
+	}
+
+	result := CheckLoginVerifyResult{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		logger.Error("解析登录校验方式错误", zap.ByteString("res", body), zap.Error(err))
+
+		return
+	}
+
+	if result.ResultCode != 0 {
+		logger.Error("检查登录校验方式失败", zap.Int("code", result.ResultCode), zap.String("msg", result.ResultMessage))
+
+		return "", errors.New("check login verify failure")
+	}
+
+	logger.Debug("登录校验方式", zap.String("login_check_code", result.LoginCheckCode))
+	return result.LoginCheckCode, nil
+}
+
+// GetMessageCode 请求发送短信验证码，需要证件号码后 4 位
+func GetMessageCode(sess *session.Session) (err error) {
+	const (
+		url0    = "https://%s/passport/web/getMessageCode"
+		referer = "https://kyfw.12306.cn/otn/resources/login.html"
+	)
+
+	if len(sess.Login.CastNum) != 4 {
+		logger.Error("短信验证码登录需要在配置 cast_num 填写证件号码后 4 位", zap.String("账号", sess.Name))
+
+		return errors.New("invalid cast_num")
+	}
+
+	payload := url.Values{}
+	payload.Add("appid", "otn")
+	payload.Add("username", sess.Login.Username)
+	payload.Add("castNum", sess.Login.CastNum)
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
+		logger.Error("获取短信验证码错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("获取短信验证码失败", zap.Int("statusCode", statusCode), zap.ByteString("res", body))
+
+		return errors.New("get message code failure")
+	}
+
+	type GetMessageCodeResult struct {
+		ResultCode    int    `json:"result_code"`
+		ResultMessage string `json:"result_message"`
+	}
+
+	result := GetMessageCodeResult{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		logger.Error("解析获取短信验证码返回信息错误", zap.ByteString("res", body), zap.Error(err))
+
+		return
+	}
+
+	if result.ResultCode != 0 {
+		logger.Error("获取短信验证码失败", zap.Int("code", result.ResultCode), zap.String("msg", result.ResultMessage))
+
+		return errors.New("get message code failure")
+	}
+
+	logger.Info("短信验证码已发送", zap.String("账号", sess.Name), zap.String("msg", result.ResultMessage))
 	return
 }
+
+// readMessageCode 读取用户输入的短信验证码
+// 配置了 sms_code_addr 时通过本地 HTTP 接口提交（如 http://127.0.0.1:8000/?code=123456），否则从标准输入读取
+func readMessageCode(sess *session.Session) (code string, err error) {
+	if sess.Login.SMSCodeAddr != "" {
+		return readMessageCodeHTTP(sess, sess.Login.SMSCodeAddr)
+	}
+
+	fmt.Fprintf(os.Stdout, "请输入账号 %s 收到的短信验证码: ", sess.Name)
+
+	if code, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+		logger.Error("读取短信验证码错误", zap.Error(err))
+
+		return
+	}
+
+	return strings.TrimSpace(code), nil
+}
+
+func readMessageCodeHTTP(sess *session.Session, addr string) (code string, err error) {
+	var l net.Listener
+	if l, err = net.Listen("tcp", addr); err != nil {
+		logger.Error("短信验证码接口监听地址错误", zap.String("addr", addr), zap.Error(err))
+
+		return
+	}
+
+	codeC := make(chan string, 1)
+	srv := &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			c := strings.TrimSpace(r.FormValue("code"))
+			if c == "" {
+				http.Error(w, "missing code", http.StatusBadRequest)
+				return
+			}
+
+			select {
+			case codeC <- c:
+				fmt.Fprintln(w, "ok")
+			default:
+				http.Error(w, "code already received", http.StatusConflict)
+			}
+		}),
+	}
+	go srv.Serve(l)
+	defer srv.Shutdown(context.Background())
+
+	logger.Info("等待提交短信验证码",
+		zap.String("账号", sess.Name),
+		zap.String("url", fmt.Sprintf("http://%s/?code=短信验证码", l.Addr().String())))
+
+	select {
+	case code = <-codeC:
+		return code, nil
+
+	case <-time.After(messageCodeTimeout):
+		logger.Error("等待短信验证码超时", zap.String("账号", sess.Name))
+
+		return "", errors.New("wait message code timeout")
+	}
+}
+
+// SMSLogin 短信验证码登录
+func SMSLogin(sess *session.Session) (err error) {
+	if err = GetMessageCode(sess); err != nil {
+		return
+	}
+
+	var code string
+	if code, err = readMessageCode(sess); err != nil {
+		return
+	}
+
+	// 登录
+	if err = DoLogin(sess, sess.Login.Username, sess.Login.Password, "", code); err != nil {
+		return
+	}
+
+	return authAndGetUserInfo(sess)
+}
@@ -5,17 +5,16 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 
 	"go.uber.org/zap"
 )
 
-func GetUserInfo(jar *cookiejar.Jar, tk string) (err error) {
+func GetUserInfo(sess *session.Session, tk string) (err error) {
 	const (
 		url0    = "https://%s/otn/uamauthclient"
 		referer = "https://kyfw.12306.cn/otn/passport?redirect=/otn/login/userLogin"
@@ -24,7 +23,7 @@ func GetUserInfo(jar *cookiejar.Jar, tk string) (err error) {
 	payload.Add("tk", tk)
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -32,7 +31,7 @@ func GetUserInfo(jar *cookiejar.Jar, tk string) (err error) {
 		body       []byte
 		statusCode int
 	)
-	if body, statusCode, err = httpcli.DoHttp(req, jar); err != nil {
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
 		logger.Error("获取用户信息请求错误", zap.Error(err))
 
 		return err
@@ -2,124 +2,87 @@ package main
 
 import (
 	"flag"
-	"gogo12306/cdn"
-	"gogo12306/common"
+	"fmt"
 	"gogo12306/config"
-	"gogo12306/cookie"
 	"gogo12306/logger"
-	"gogo12306/login"
-	"gogo12306/ticket"
-	"gogo12306/worker"
 	"math/rand"
-	"net/http/cookiejar"
 	"os"
-	"os/signal"
-	"syscall"
+	"strings"
 	"time"
-
-	"go.uber.org/zap"
 )
 
-func main() {
-	isCDN := flag.Bool("c", false, "筛选延时在 300ms 内的可用 CDN")
-	isGrab := flag.Bool("g", false, "开始抢票")
-	flag.Parse()
-
-	config.Init("config.json")
-
-	logger.Init(
-		config.Cfg.Logger.IsDevelop,
-		config.Cfg.Logger.LogFilepath,
-		config.Cfg.Logger.LogLevel,
-		config.Cfg.Logger.LogSplitMBSize,
-		config.Cfg.Logger.LogKeepDays,
-	)
-
-	if len(os.Args) > 1 {
-		rand.Seed(time.Now().UnixNano())
-
-		switch os.Args[1] {
-		case "-c": // 筛选延时在 300ms 内的可用 CDN
-			logger.Info("筛选延时在 300ms 内的可用 CDN", zap.Bool("cdn", *isCDN))
-
-			cdn.FilterCDN(config.Cfg.CDN.CDNPath, config.Cfg.CDN.GoodCDNPath)
-			return
-
-		case "-g": // 开始抢票
-			logger.Info("开始抢票", zap.Bool("grab", *isGrab))
-
-			common.CheckOperationPeriod()
-
-			var err error
-			if err = cdn.LoadCDN(config.Cfg.CDN.GoodCDNPath); err != nil {
-				return
-			}
-
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
-			// 站点信息
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
-
-			if err = ticket.InitStations(); err != nil {
-				return
-			}
-
-			if err = ticket.InitLeftTickerURL(); err != nil {
-				return
-			}
+// command 子命令，name 可以有多个单词（如 cdn filter），args 为子命令名之后的参数
+type command struct {
+	name  string
+	args  string // 参数说明
+	usage string
+	run   func(cfgPath string, cfg *config.Config, args []string) (err error)
+}
 
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
-			// 登录
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
+var commands = []command{
+	{"cdn filter", "", "筛选延时在 300ms 内的可用 CDN", runCDNFilter},
+	{"grab", "", "开始抢票", runGrab},
+	{"query", "[-type GD] [-depart 08:00-12:00] [-arrive 14:00-18:00] [-seats 二等座,一等座] [-format table|json|csv] [-student] [-price] <出发站> <到达站> <出发日期>", "查询余票，不需要登录", runQuery},
+	{"transfer", "[-hubs 武汉市,长沙南] [-max-hubs 8] [-min 30] [-max 240] [-seats 二等座,一等座] [-limit 20] [-student] <出发站> <到达站> <出发日期>", "查询经过中转站的换乘方案，不需要登录", runTransfer},
+	{"train", "<车次> <始发日期>", "查询列车的停靠站和到发时刻，不需要登录", runTrain},
+	{"passengers", "[-account 账号名]", "登录并列出联系人", runPassengers},
+	{"orders", "[-account 账号名]", "登录并列出未完成和未出行的订单", runOrders},
+	{"notify test", "", "发送一条测试消息，检查消息通知配置", runNotifyTest},
+	{"stations search", "<关键字>", "按站点名、电报码、拼音或拼音首字母搜索站点，支持前缀和模糊匹配", runStationsSearch},
+	{"stations update", "[-o 文件路径]", "从 12306 重新获取站点列表和开售时间，保存到缓存文件或指定文件", runStationsUpdate},
+	{"validate", "", "检查配置文件并列出所有问题", runValidate},
+	{"mock", "", "启动本地模拟 12306 服务器，用于离线测试", runMock},
+}
 
-			var jar *cookiejar.Jar
-			if jar, err = cookiejar.New(nil); err != nil {
-				logger.Error("创建 Jar 错误", zap.Error(err))
-				return
-			}
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [-config 配置文件路径] <命令> [参数]\n\n", os.Args[0])
+	fmt.Fprintln(out, "命令:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %s %s\n    \t%s\n", cmd.name, cmd.args, cmd.usage)
+	}
 
-			if err = cookie.SetCookie(jar,
-				config.Cfg.Login.GetCookieMethod,
-				config.Cfg.Login.ChromeBrowserPath,
-				config.Cfg.Login.ChromeDriverPath,
-				config.Cfg.Login.RailExpiration,
-				config.Cfg.Login.RailDeviceID,
-			); err != nil {
-				return
-			}
+	fmt.Fprintln(out, "\n选项:")
+	flag.PrintDefaults()
+}
 
-			// 先登录，好处时后面购票时不用再花时间登录，抢到票的几率增大
-			// 但也有可能遇到当余票足够准备下单时，系统已自动退出登录，还是需要重新登录
-			if config.Cfg.Login.Username != "" && config.Cfg.Login.Password != "" {
-				if err = login.Login(jar); err != nil {
-					return
-				}
+// findCommand 按参数匹配子命令，返回子命令和剩余参数
+func findCommand(args []string) (cmd *command, rest []string) {
+	for i := range commands {
+		words := strings.Fields(commands[i].name)
+		if len(args) >= len(words) && strings.Join(args[:len(words)], " ") == commands[i].name {
+			return &commands[i], args[len(words):]
+		}
+	}
 
-				login.CheckLoginTimer(jar)
-			}
+	return nil, nil
+}
 
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
-			// 刷票任务
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
+func main() {
+	cfgPath := flag.String("config", "config.json", "配置文件路径")
+	flag.Usage = usage
+	flag.Parse()
 
-			for _, taskCfg := range config.Cfg.Tasks {
-				var task *worker.Task
-				if task, err = ticket.ParseTask(&taskCfg); err != nil || task == nil {
-					logger.Error("转换任务配置出现错误", zap.Any("任务配置", taskCfg), zap.Error(err))
-					return
-				}
+	cmd, args := findCommand(flag.Args())
+	if cmd == nil {
+		usage()
+		os.Exit(2)
+	}
 
-				worker.DoTask(jar, task)
-			}
+	cfg := config.Init(*cfgPath)
 
-			///////////////////////////////////////////////////////////////////////////////////////////////////////////
+	logger.Init(
+		cfg.Logger.IsDevelop,
+		cfg.Logger.LogFilepath,
+		cfg.Logger.LogLevel,
+		cfg.Logger.LogSplitMBSize,
+		cfg.Logger.LogKeepDays,
+	)
 
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, syscall.SIGHUP, syscall.SIGINT, syscall.SIGQUIT, syscall.SIGTERM)
-			<-c
+	rand.Seed(time.Now().UnixNano())
 
-			return
-		}
+	if err := cmd.run(*cfgPath, cfg, args); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
 	}
-
-	flag.Usage()
 }
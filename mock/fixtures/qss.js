@@ -0,0 +1 @@
+var citys = {"北京北":"12:30","北京南":"13:30","广州":"12:00","广州东":"12:30","广州南":"12:30","上海":"13:30","上海虹桥":"13:30","上海南":"13:30","深圳北":"13:00","武昌":"10:30","长沙南":"12:30"}
\ No newline at end of file
@@ -0,0 +1 @@
+var station_names ='@bjb|北京北|VAP|beijingbei|bjb|0|0357|北京@bjn|北京南|VNP|beijingnan|bjn|1|0357|北京@gzh|广州|GZQ|guangzhou|gz|33|1014|广州@gzd|广州东|GGQ|guangzhoudong|gzd|34|1014|广州@gzn|广州南|IZQ|guangzhounan|gzn|5|1014|广州@sha|上海|SHH|shanghai|sh|36|0712|上海@shh|上海虹桥|AOH|shanghaihongqiao|shhq|37|0712|上海@shn|上海南|SNH|shanghainan|shn|38|0712|上海@szb|深圳北|IOQ|shenzhenbei|szb|39|1015|深圳@wch|武昌|WCN|wuchang|wc|40|0915|武汉@csn|长沙南|CWQ|changshanan|csn|41|1007|长沙';
\ No newline at end of file
@@ -0,0 +1,811 @@
+package mock_test
+
+import (
+	"gogo12306/captcha"
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/cookie"
+	"gogo12306/logger"
+	"gogo12306/login"
+	"gogo12306/mock"
+	"gogo12306/order"
+	"gogo12306/session"
+	"gogo12306/ticket"
+	"gogo12306/worker"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestGrab(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	grab(t, "", &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+}
+
+func TestGrabWithOrderCaptcha(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	// 替换录制数据，提交订单时要求验证码
+	dir := t.TempDir()
+	info, _ := ioutil.ReadFile("fixtures/check_order_info.json")
+	info = []byte(strings.Replace(string(info), `"ifShowPassCode":"N"`, `"ifShowPassCode":"Y"`, 1))
+	ioutil.WriteFile(filepath.Join(dir, "check_order_info.json"), info, 0644)
+
+	grab(t, dir, &config.LoginConfig{
+		Username:      "mock",
+		Password:      "mock",
+		CaptchaSolver: captcha.SolverStub,
+	})
+}
+
+// loginMock 创建会话，获取站点列表和余票查询地址并登录模拟服务器
+func loginMock(srv *httptest.Server, loginCfg *config.LoginConfig) (sess *session.Session, err error) {
+	host := srv.Listener.Addr().String()
+	pool := cdn.NewPool()
+	pool.SetEndpoint(host, host)
+
+	if sess, err = session.New(&config.Config{}, loginCfg, pool, common.NewStations()); err != nil {
+		return
+	}
+
+	if err = ticket.InitStations(sess); err != nil {
+		return
+	}
+
+	if err = ticket.InitLeftTickerURL(sess); err != nil {
+		return
+	}
+
+	if err = cookie.SetCookie(sess.Jar, 3, "", "", "mock", "mock"); err != nil {
+		return
+	}
+
+	err = login.Login(sess)
+
+	return
+}
+
+func grab(t *testing.T, fixturesDir string, loginCfg *config.LoginConfig) {
+	srv := mock.NewServer(fixturesDir)
+	defer srv.Close()
+
+	sess, err := loginMock(srv, loginCfg)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	var task *worker.Task
+	if task, err = ticket.ParseTask(sess, &config.TaskConfig{
+		OrderType:      1,
+		BlackTime:      30,
+		From:           "广州南",
+		To:             "上海虹桥",
+		StartDates:     []string{time.Now().AddDate(0, 0, 1).Format("2006-01-02")},
+		TrainCodes:     []string{"D933"},
+		Seats:          []string{"二等座"},
+		ChooseSeats:    []string{"1A"},
+		SeatDetailType: []string{"0", "0", "0"},
+		Passengers:     []string{"张三"},
+	}); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err = ticket.QueryLeftTicket(sess, task); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	select {
+	case <-task.Done:
+	default:
+		t.Error("task not done")
+	}
+}
+
+func TestGrabRoundTrip(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	sess, err := loginMock(srv, &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var task *worker.Task
+	if task, err = ticket.ParseTask(sess, &config.TaskConfig{
+		OrderType:      1,
+		BlackTime:      30,
+		From:           "广州南",
+		To:             "上海虹桥",
+		StartDates:     []string{time.Now().AddDate(0, 0, 1).Format("2006-01-02")},
+		TrainCodes:     []string{"D933"},
+		BackStartDates: []string{time.Now().AddDate(0, 0, 3).Format("2006-01-02")},
+		BackTrainCodes: []string{"D934"},
+		Seats:          []string{"二等座"},
+		ChooseSeats:    []string{"1A"},
+		SeatDetailType: []string{"0", "0", "0"},
+		Passengers:     []string{"张三"},
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 去程下单成功后切换到返程，任务还没有结束
+	if err = ticket.QueryLeftTicket(sess, task); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if task.TourFlag != worker.TourFlagBack || task.From != "上海虹桥" || task.To != "广州南" ||
+		len(task.SaleTimes) != 1 || task.Back != nil {
+		t.Fatalf("task not switched to back trip: %s %s -> %s", task.TourFlag, task.From, task.To)
+	}
+
+	select {
+	case <-task.Done:
+		t.Fatal("task done before back trip")
+	default:
+	}
+
+	if err = ticket.QueryLeftTicket(sess, task); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	select {
+	case <-task.Done:
+	default:
+		t.Error("task not done")
+	}
+}
+
+func TestTransfer(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	sess, err := loginMock(srv, &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	from, _ := sess.Stations.Resolve("广州南")
+	to, _ := sess.Stations.Resolve("上海虹桥")
+
+	// 没有配置中转站时使用默认的中转城市，模拟数据只有长沙南可以换乘
+	var plans []*ticket.TransferPlan
+	if plans, err = ticket.SearchTransfers(sess, from, to, nil, ticket.DefaultTransferMaxHubs, "2022-01-02",
+		ticket.DefaultTransferMinWait, ticket.DefaultTransferMaxWait, false); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var got []string
+	for _, plan := range plans {
+		got = append(got, plan.First.TrainCode+"+"+plan.Second.TrainCode)
+	}
+
+	// 按到达时间排序，到达时间相同时全程历时短的在前
+	want := "G6102+G1372,G1002+G1374,G6102+G1374"
+	if strings.Join(got, ",") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+
+	if plans[0].Wait != time.Hour || plans[0].SecondDate != "2022-01-02" {
+		t.Errorf("unexpected plan: %+v", plans[0])
+	}
+
+	// 只查询第一个中转站（北京）时没有方案
+	if plans, err = ticket.SearchTransfers(sess, from, to, nil, 1, "2022-01-02",
+		ticket.DefaultTransferMinWait, ticket.DefaultTransferMaxWait, false); err != nil || len(plans) != 0 {
+		t.Errorf("max hubs 1: %d plans, %v", len(plans), err)
+	}
+
+	newTask := func(excludeTrainCodes []string) *worker.Task {
+		task, err := ticket.ParseTask(sess, &config.TaskConfig{
+			OrderType:         1,
+			BlackTime:         30,
+			From:              "广州南",
+			To:                "上海虹桥",
+			StartDates:        []string{time.Now().AddDate(0, 0, 1).Format("2006-01-02")},
+			ExcludeTrainCodes: excludeTrainCodes,
+			Transfer:          true,
+			TransferHubs:      []string{"长沙市"},
+			TransferAfter:     2,
+			Seats:             []string{"二等座"},
+			ChooseSeats:       []string{"1A"},
+			SeatDetailType:    []string{"0", "0", "0"},
+			Passengers:        []string{"张三"},
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		return task
+	}
+
+	isDone := func(task *worker.Task) bool {
+		select {
+		case <-task.Done:
+			return true
+		default:
+			return false
+		}
+	}
+
+	// 直达车次连续失败两轮后才查询中转方案
+	task := newTask(nil)
+	for i, want := range []bool{false, true} {
+		if err = ticket.QueryLeftTicket(sess, task); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if done := isDone(task); done != want {
+			t.Errorf("cycle %d: done %v, want %v", i+1, done, want)
+		}
+	}
+
+	// 排除的车次不能作为中转方案的任何一程
+	task = newTask([]string{"G1372", "G1374"})
+	for i := 0; i < 2; i++ {
+		if err = ticket.QueryLeftTicket(sess, task); err != nil {
+			t.Fatal(err.Error())
+		}
+	}
+
+	if isDone(task) {
+		t.Error("excluded second legs should not be ordered")
+	}
+}
+
+func TestLongerSegment(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	// 替换录制数据，G1002 广州南到长沙南没有余票，广州南到终到站武昌有票
+	dir := t.TempDir()
+	query, _ := ioutil.ReadFile("fixtures/query_IZQ_CWQ.json")
+	query = []byte(strings.Replace(string(query), "||||8|12|3|", "||||无|12|3|", 1))
+	ioutil.WriteFile(filepath.Join(dir, "query_IZQ_CWQ.json"), query, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "query_IZQ_WCN.json"), []byte(`{"httpstatus": 200, "data": {"result": ["MOCKSECRETG1002|预订|6i000G100200|G1002|IZQ|WCN|IZQ|WCN|10:00|14:00|04:00|Y|O055300000M0933000009174800000|20220101|3|Q6|01|11|0|0|||||||无||||有|12|3||O0M090|OM9|1|0|||||||||"], "flag": "1", "map": {"IZQ": "广州南", "WCN": "武昌"}}, "messages": "", "status": true}`), 0644)
+
+	srv := mock.NewServer(dir)
+	defer srv.Close()
+
+	sess, err := loginMock(srv, &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var infos []*common.LeftTicketInfo
+	if infos, err = ticket.QueryLeftTickets(sess, "IZQ", "CWQ", "2022-01-02", false); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var segments []*ticket.LongerSegment
+	if segments, err = ticket.QueryLongerSegments(sess, infos[1], "2022-01-02", false); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(segments) != 1 || segments[0].Info.To != "武昌" || segments[0].StartDate != "2022-01-02" {
+		t.Fatalf("unexpected segments: %+v", segments)
+	}
+
+	var task *worker.Task
+	if task, err = ticket.ParseTask(sess, &config.TaskConfig{
+		OrderType:      1,
+		BlackTime:      30,
+		From:           "广州南",
+		To:             "长沙南",
+		StartDates:     []string{time.Now().AddDate(0, 0, 1).Format("2006-01-02")},
+		TrainCodes:     []string{"G1002"},
+		LongerSegment:  true,
+		Seats:          []string{"二等座"},
+		ChooseSeats:    []string{"1A"},
+		SeatDetailType: []string{"0", "0", "0"},
+		Passengers:     []string{"张三"},
+	}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = ticket.QueryLeftTicket(sess, task); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	select {
+	case <-task.Done:
+	default:
+		t.Error("task not done")
+	}
+
+	// 刚查询过的车次在间隔内不再查询更长区间
+	now := time.Now()
+	if task.LongerSegmentDue("G1002", task.StartDates[0], now) {
+		t.Error("longer segment probed again within interval")
+	}
+
+	if !task.LongerSegmentDue("G1002", task.StartDates[0], now.Add(ticket.DefaultLongerSegmentInterval)) {
+		t.Error("longer segment not due after interval")
+	}
+}
+
+func TestTrainStops(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	sess, err := loginMock(srv, &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var trainNo string
+	if trainNo, err = ticket.SearchTrainNo(sess, "z100", "2022-01-02"); err != nil {
+		t.Fatal(err.Error())
+	} else if trainNo != "6i000Z10000" {
+		t.Fatalf("got train no %s", trainNo)
+	}
+
+	var stops common.TrainStops
+	if stops, err = ticket.QueryTrainStops(sess, trainNo, "", "", "2022-01-02"); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 长沙 23:58 出发，南昌 04:10 到达时已是第二天
+	if len(stops) != 4 || stops[1].DepartDay != 0 || stops[2].ArriveDay != 1 || stops[2].StopMinutes != 6 ||
+		stops[0].ArriveTime != "" || stops[3].DepartTime != "" || stops[3].TelegramCode != "SHH" {
+		t.Fatalf("unexpected stops: %+v %+v %+v %+v", stops[0], stops[1], stops[2], stops[3])
+	}
+
+	if !stops.StopsAt("南昌") || stops.StopsAt("武昌") {
+		t.Error("unexpected StopsAt")
+	}
+
+	// 第二次从缓存中读取
+	var cached common.TrainStops
+	if cached, err = ticket.QueryTrainStops(sess, trainNo, "", "", "2022-01-02"); err != nil {
+		t.Fatal(err.Error())
+	} else if cached[0] != stops[0] {
+		t.Error("train stops not cached")
+	}
+
+	if _, err = ticket.SearchTrainNo(sess, "G9", "2022-01-02"); err == nil {
+		t.Error("expected error for unknown train")
+	}
+}
+
+func TestMaxPrice(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	sess, err := loginMock(srv, &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	var infos []*common.LeftTicketInfo
+	if infos, err = ticket.QueryLeftTickets(sess, "IZQ", "AOH", "2022-01-02", false); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	ticket.AttachTicketPrices(sess, infos, "2022-01-02")
+	if infos[0].Price(3) != 663 || infos[0].Price(2) != 1114 || infos[0].Price(0) != 1748 || infos[0].Price(9) != 0 {
+		t.Fatalf("unexpected prices: %v", infos[0].Prices)
+	}
+
+	// 二等座 663 元，超过 max_price 时不下单，max_prices 优先于 max_price
+	// D933 没有无座（663 元），一等座 1114 元比无座贵 451 元，超过 max_upgrade_price 时不下单
+	newTask := func(sess *session.Session, seats []string, maxPrice float64, maxPrices map[string]float64, maxUpgradePrice float64) *worker.Task {
+		task, err := ticket.ParseTask(sess, &config.TaskConfig{
+			OrderType:       1,
+			BlackTime:       30,
+			From:            "广州南",
+			To:              "上海虹桥",
+			StartDates:      []string{time.Now().AddDate(0, 0, 1).Format("2006-01-02")},
+			TrainCodes:      []string{"D933"},
+			Seats:           seats,
+			ChooseSeats:     []string{"1A"},
+			SeatDetailType:  []string{"0", "0", "0"},
+			Passengers:      []string{"张三"},
+			MaxPrice:        maxPrice,
+			MaxPrices:       maxPrices,
+			MaxUpgradePrice: maxUpgradePrice,
+		})
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		return task
+	}
+
+	isDone := func(task *worker.Task) bool {
+		select {
+		case <-task.Done:
+			return true
+		default:
+			return false
+		}
+	}
+
+	for _, c := range []struct {
+		seats           []string
+		maxPrice        float64
+		maxPrices       map[string]float64
+		maxUpgradePrice float64
+		done            bool
+	}{
+		{[]string{"二等座"}, 600, nil, 0, false},
+		{[]string{"二等座"}, 700, nil, 0, true},
+		{[]string{"二等座"}, 600, map[string]float64{"二等座": 700}, 0, true},
+		{[]string{"二等座"}, 0, map[string]float64{"二等座": 600}, 0, false},
+		{[]string{"无座", "一等座"}, 0, nil, 400, false},
+		{[]string{"无座", "一等座"}, 0, nil, 500, true},
+		{[]string{"一等座"}, 0, nil, 400, true}, // 没有排在前面的座席时不限制差价
+	} {
+		task := newTask(sess, c.seats, c.maxPrice, c.maxPrices, c.maxUpgradePrice)
+		if err = ticket.QueryLeftTicket(sess, task); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if done := isDone(task); done != c.done {
+			t.Errorf("seats %v, max_price %v, max_prices %v, max_upgrade_price %v: done %v, want %v",
+				c.seats, c.maxPrice, c.maxPrices, c.maxUpgradePrice, done, c.done)
+		}
+	}
+
+	// 查询不到票价时照常下单
+	dir := t.TempDir()
+	if err = ioutil.WriteFile(filepath.Join(dir, "query_ticket_price.json"), []byte(`{"status": false, "messages": ["查询失败"]}`), 0644); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	srv2 := mock.NewServer(dir)
+	defer srv2.Close()
+
+	if sess, err = loginMock(srv2, &config.LoginConfig{Username: "mock", Password: "mock"}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	task := newTask(sess, []string{"二等座"}, 600, nil, 0)
+	if err = ticket.QueryLeftTicket(sess, task); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !isDone(task) {
+		t.Error("task should be done when the price is unknown")
+	}
+
+	// 查询失败的结果会缓存，票价接口恢复后短时间内不再重复查询
+	if infos, err = ticket.QueryLeftTickets(sess, "IZQ", "AOH", "2022-01-03", false); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err = ticket.QueryTicketPrices(sess, infos[0], "2022-01-03"); err == nil {
+		t.Fatal("price lookup should fail")
+	}
+
+	if err = os.Remove(filepath.Join(dir, "query_ticket_price.json")); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if _, err = ticket.QueryTicketPrices(sess, infos[0], "2022-01-03"); err == nil {
+		t.Error("failed price lookup should be cached")
+	}
+}
+
+func TestTrainFilter(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	sess, err := loginMock(srv, &config.LoginConfig{
+		Username: "mock",
+		Password: "mock",
+	})
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	// 不填写 train_codes，按规则选中 D933，排除 D933 后没有可以下单的车次
+	for _, c := range []struct {
+		exclude []string
+		done    bool
+	}{
+		{nil, true},
+		{[]string{"d933"}, false},
+	} {
+		var task *worker.Task
+		if task, err = ticket.ParseTask(sess, &config.TaskConfig{
+			OrderType:  1,
+			BlackTime:  30,
+			From:       "广州市",
+			To:         "上海市",
+			StartDates: []string{time.Now().AddDate(0, 0, 1).Format("2006-01-02")},
+			TrainFilter: &config.TrainFilterConfig{
+				TrainTypes:         "DG",
+				Depart:             "06:00-12:00",
+				MaxDurationMinutes: 12 * 60,
+				FromStations:       []string{"广州南"},
+				ToStations:         []string{"上海虹桥"},
+			},
+			ExcludeTrainCodes: c.exclude,
+			Seats:             []string{"二等座"},
+			ChooseSeats:       []string{"1A"},
+			SeatDetailType:    []string{"0", "0", "0"},
+			Passengers:        []string{"张三"},
+		}); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if err = ticket.QueryLeftTicket(sess, task); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		select {
+		case <-task.Done:
+			if !c.done {
+				t.Errorf("exclude %v: task should not be done", c.exclude)
+			}
+		default:
+			if c.done {
+				t.Errorf("exclude %v: task not done", c.exclude)
+			}
+		}
+	}
+}
+
+func TestQRLogin(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	pool := cdn.NewPool()
+	pool.SetEndpoint(host, host)
+
+	var (
+		err  error
+		sess *session.Session
+	)
+	if sess, err = session.New(&config.Config{}, &config.LoginConfig{
+		LoginMethod: login.LoginMethodQR,
+		QRImagePath: filepath.Join(t.TempDir(), "qrcode.png"),
+	}, pool, common.NewStations()); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err = login.Login(sess); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if sess.GetPassenger("张三") == nil {
+		t.Error("passenger list not loaded")
+	}
+}
+
+func TestSMSLogin(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	// 替换录制数据，要求统一认证登录并使用短信验证码校验
+	dir := t.TempDir()
+	conf, _ := ioutil.ReadFile("fixtures/login_conf.json")
+	conf = []byte(strings.NewReplacer(
+		`"is_uam_login":"N"`, `"is_uam_login":"Y"`,
+		`"is_message_passCode":"N"`, `"is_message_passCode":"Y"`,
+	).Replace(string(conf)))
+	ioutil.WriteFile(filepath.Join(dir, "login_conf.json"), conf, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "check_login_verify.json"), []byte(`{"result_code":0,"login_check_code":"3"}`), 0644)
+
+	srv := mock.NewServer(dir)
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	pool := cdn.NewPool()
+	pool.SetEndpoint(host, host)
+
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	addr := l.Addr().String()
+	l.Close()
+
+	var (
+		err  error
+		sess *session.Session
+	)
+	if sess, err = session.New(&config.Config{}, &config.LoginConfig{
+		Username:    "mock",
+		Password:    "mock",
+		CastNum:     "1234",
+		SMSCodeAddr: addr,
+	}, pool, common.NewStations()); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// 模拟用户提交短信验证码
+	go func() {
+		for i := 0; i < 50; i++ {
+			time.Sleep(100 * time.Millisecond)
+
+			if res, err := http.Get("http://" + addr + "/?code=123456"); err == nil {
+				res.Body.Close()
+				return
+			}
+		}
+	}()
+
+	if err = login.Login(sess); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if sess.GetPassenger("张三") == nil {
+		t.Error("passenger list not loaded")
+	}
+}
+
+func TestCaptchaLogin(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	// 替换录制数据，要求统一认证登录并使用图片验证码校验
+	dir := t.TempDir()
+	conf, _ := ioutil.ReadFile("fixtures/login_conf.json")
+	conf = []byte(strings.Replace(string(conf), `"is_uam_login":"N"`, `"is_uam_login":"Y"`, 1))
+	ioutil.WriteFile(filepath.Join(dir, "login_conf.json"), conf, 0644)
+	ioutil.WriteFile(filepath.Join(dir, "check_login_verify.json"), []byte(`{"result_code":0,"login_check_code":"1"}`), 0644)
+
+	srv := mock.NewServer(dir)
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	pool := cdn.NewPool()
+	pool.SetEndpoint(host, host)
+
+	var (
+		err  error
+		sess *session.Session
+	)
+	if sess, err = session.New(&config.Config{}, &config.LoginConfig{
+		Username:      "mock",
+		Password:      "mock",
+		CaptchaSolver: captcha.SolverOCR,
+		OCRUrl:        srv.URL + "/ocr",
+	}, pool, common.NewStations()); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if err = login.Login(sess); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if sess.GetPassenger("张三") == nil {
+		t.Error("passenger list not loaded")
+	}
+}
+
+func TestQueryOrders(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	pool := cdn.NewPool()
+	pool.SetEndpoint(host, host)
+
+	sess, err := session.New(&config.Config{}, &config.LoginConfig{}, pool, common.NewStations())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	var orders []*order.OrderInfo
+	if orders, err = order.QueryMyOrderNoComplete(sess); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if len(orders) != 1 || orders[0].SequenceNo != "EMOCK00001" || len(orders[0].Tickets) != 1 {
+		t.Errorf("unexpected no complete orders: %+v", orders)
+	}
+
+	if orders, err = order.QueryMyOrder(sess); err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	if len(orders) != 1 || orders[0].SequenceNo != "EMOCK00000" {
+		t.Errorf("unexpected orders: %+v", orders)
+	}
+}
+
+func TestQueryCity(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	srv := mock.NewServer("")
+	defer srv.Close()
+
+	host := srv.Listener.Addr().String()
+	pool := cdn.NewPool()
+	pool.SetEndpoint(host, host)
+
+	sess, err := session.New(&config.Config{}, &config.LoginConfig{}, pool, common.NewStations())
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = ticket.InitStations(sess); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if err = ticket.InitLeftTickerURL(sess); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	for _, c := range []struct {
+		from, to string
+		want     []string
+	}{
+		{"广州南", "上海虹桥", []string{"D933", "G1314"}},
+		{"广州市", "上海市", []string{"D933", "G1314", "Z100"}},
+		{"广州,广州东", "上海", []string{"Z100"}},
+		{"广州东", "上海市", nil},
+	} {
+		var from, to []*common.StationInfo
+		if from, err = sess.Stations.Resolve(c.from); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		if to, err = sess.Stations.Resolve(c.to); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var infos []*common.LeftTicketInfo
+		if infos, err = ticket.QueryStationsLeftTickets(sess, from, to, "2022-01-02", false); err != nil {
+			t.Fatal(err.Error())
+		}
+
+		var got []string
+		for _, info := range infos {
+			got = append(got, info.TrainCode)
+		}
+
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Errorf("%s -> %s: got %v, want %v", c.from, c.to, got, c.want)
+		}
+	}
+}
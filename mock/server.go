@@ -0,0 +1,180 @@
+package mock
+
+import (
+	"embed"
+	"io/fs"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+
+	"gogo12306/logger"
+
+	"go.uber.org/zap"
+)
+
+// 内置的 12306 接口录制数据
+//
+//go:embed fixtures
+var fixtures embed.FS
+
+// 接口路径 -> 录制数据文件名
+// www.12306.cn、kyfw.12306.cn 和 search.12306.cn 共用同一个模拟服务器，所以按路径区分即可
+var routes = map[string]string{
+	// www.12306.cn
+	"/index/index.html": "index.html",
+	"/index/script/core/common/station_name_mock.js": "station_name.js",
+	"/index/script/core/common/qss_mock.js":          "qss.js",
+
+	// 余票查询
+	"/otn/leftTicket/init":             "init.html",
+	"/otn/leftTicket/query":            "query.json",
+	"/otn/leftTicket/queryTicketPrice": "query_ticket_price.json",
+
+	// 列车时刻表
+	"/otn/czxx/queryByTrainNo": "query_by_train_no.json",
+	"/search/v1/train/search":  "train_search.json",
+
+	// 登录
+	"/otn/login/conf":                        "login_conf.json",
+	"/otn/login/loginAysnSuggest":            "login_aysn_suggest.json",
+	"/otn/login/checkUser":                   "check_user.json",
+	"/otn/resources/login.html":              "login.html",
+	"/otn/uamauthclient":                     "uamauthclient.json",
+	"/passport/web/login":                    "passport_login.json",
+	"/passport/web/auth/uamtk-static":        "uamtk_static.json",
+	"/passport/captcha/captcha-image64":      "captcha_image64.json",
+	"/passport/captcha/captcha-check":        "captcha_check.json",
+	"/passport/web/create-qr64":              "create_qr64.json",
+	"/passport/web/checkqr":                  "checkqr.json",
+	"/passport/web/checkLoginVerify":         "check_login_verify.json",
+	"/passport/web/getMessageCode":           "get_message_code.json",
+	"/otn/confirmPassenger/getPassengerDTOs": "passengers.json",
+
+	// 验证码 OCR，并非 12306 的接口，方便测试验证码登录流程
+	"/ocr": "ocr.json",
+
+	// 普通购票
+	"/otn/leftTicket/submitOrderRequest":           "submit_order.json",
+	"/otn/confirmPassenger/initDc":                 "init_dc.html",
+	"/otn/confirmPassenger/checkOrderInfo":         "check_order_info.json",
+	"/otn/confirmPassenger/getQueueCount":          "get_queue_count.json",
+	"/otn/confirmPassenger/confirmSingleForQueue":  "confirm_single_for_queue.json",
+	"/otn/confirmPassenger/queryOrderWaitTime":     "query_order_wait_time.json",
+	"/otn/confirmPassenger/resultOrderForDcQueue":  "result_order_for_dc_queue.json",
+	"/otn/confirmPassenger/initWc":                 "init_dc.html",
+	"/otn/confirmPassenger/initFc":                 "init_dc.html",
+	"/otn/confirmPassenger/confirmGoForQueue":      "confirm_single_for_queue.json",
+	"/otn/confirmPassenger/confirmBackForQueue":    "confirm_single_for_queue.json",
+	"/otn/confirmPassenger/resultOrderForWcQueue":  "result_order_for_dc_queue.json",
+	"/otn/confirmPassenger/resultOrderForFcQueue":  "result_order_for_dc_queue.json",
+	"/otn/confirmPassenger/autoSubmitOrderRequest": "submit_order.json",
+
+	// 订单查询
+	"/otn/queryOrder/queryMyOrderNoComplete": "query_my_order_no_complete.json",
+	"/otn/queryOrder/queryMyOrder":           "query_my_order.json",
+
+	// 候补
+	"/otn/afterNate/chechFace":          "chech_face.json",
+	"/otn/afterNate/getSuccessRate":     "get_success_rate.json",
+	"/otn/afterNate/submitOrderRequest": "afternate_submit_order.json",
+	"/otn/afterNate/passengerInitApi":   "passenger_init_api.json",
+	"/otn/afterNate/getQueueNum":        "get_queue_num.json",
+	"/otn/afterNate/confirmHB":          "confirm_hb.json",
+	"/otn/afterNate/queryQueue":         "query_queue.json",
+}
+
+// 按查询参数区分录制数据的接口：接口路径 -> 查询参数名
+// 如余票查询优先使用 query_AOH_IZQ.json（上海虹桥到广州南），没有时再使用 query.json
+var variants = map[string][]string{
+	"/otn/leftTicket/query":    {"leftTicketDTO.from_station", "leftTicketDTO.to_station"},
+	"/otn/czxx/queryByTrainNo": {"train_no"},
+	"/search/v1/train/search":  {"keyword"},
+}
+
+// variantName 按查询参数区分的录制数据文件名，如 query_AOH_IZQ.json
+func variantName(r *http.Request, name string) string {
+	ext := path.Ext(name)
+	variant := strings.TrimSuffix(name, ext)
+	for _, key := range variants[r.URL.Path] {
+		variant += "_" + r.URL.Query().Get(key)
+	}
+
+	return variant + ext
+}
+
+// NewHandler 创建模拟 12306 接口的 Handler
+// fixturesDir 不为空时优先使用该目录下同名的录制数据，找不到时再使用内置数据
+func NewHandler(fixturesDir string) http.Handler {
+	builtin, _ := fs.Sub(fixtures, "fixtures")
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name, ok := routes[r.URL.Path]
+		if !ok {
+			logger.Warn("模拟服务器没有此接口", zap.String("method", r.Method), zap.String("path", r.URL.Path))
+
+			http.NotFound(w, r)
+			return
+		}
+
+		// 有按查询参数区分的录制数据时优先使用
+		if _, ok := variants[r.URL.Path]; ok {
+			variant := variantName(r, name)
+			if _, err := os.Stat(path.Join(fixturesDir, variant)); fixturesDir != "" && err == nil {
+				name = variant
+			} else if _, err := fs.Stat(builtin, variant); err == nil {
+				name = variant
+			}
+		}
+
+		var (
+			data []byte
+			err  error
+		)
+		if fixturesDir != "" {
+			data, err = os.ReadFile(path.Join(fixturesDir, name))
+		}
+
+		if fixturesDir == "" || err != nil {
+			if data, err = fs.ReadFile(builtin, name); err != nil {
+				logger.Error("读取模拟数据错误", zap.String("fixture", name), zap.Error(err))
+
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		logger.Debug("模拟服务器请求", zap.String("method", r.Method), zap.String("path", r.URL.Path), zap.String("fixture", name))
+
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Write(data)
+	})
+}
+
+// NewServer 启动监听随机端口的模拟服务器，主要用于测试
+func NewServer(fixturesDir string) *httptest.Server {
+	return httptest.NewTLSServer(NewHandler(fixturesDir))
+}
+
+// Start 在指定地址启动模拟服务器（HTTPS 自签名证书，本程序不校验证书）
+func Start(addr, fixturesDir string) (srv *httptest.Server, err error) {
+	var l net.Listener
+	if l, err = net.Listen("tcp", addr); err != nil {
+		logger.Error("模拟服务器监听地址错误", zap.String("addr", addr), zap.Error(err))
+
+		return nil, err
+	}
+
+	srv = httptest.NewUnstartedServer(NewHandler(fixturesDir))
+	srv.Listener.Close()
+	srv.Listener = l
+	srv.StartTLS()
+
+	logger.Info("模拟服务器已启动", zap.String("addr", l.Addr().String()))
+	return
+}
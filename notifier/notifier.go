@@ -7,12 +7,12 @@ import (
 )
 
 // Broadcast 广播刷票成功的消息
-func Broadcast(msg string) (err error) {
-	if err = serverchan.Notify(&config.Cfg.Notifier.ServerChan, msg); err != nil {
+func Broadcast(cfg *config.NotifierConfig, msg string) (err error) {
+	if err = serverchan.Notify(&cfg.ServerChan, msg); err != nil {
 		return
 	}
 
-	if err = wxpusher.Notify(&config.Cfg.Notifier.WXPusher, msg); err != nil {
+	if err = wxpusher.Notify(&cfg.WXPusher, msg); err != nil {
 		return
 	}
 
@@ -6,13 +6,12 @@ import (
 	"fmt"
 	"log"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
 	"gogo12306/order/common"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -24,6 +23,7 @@ type AutoSubmitOrderRequest struct {
 	QueryToStationName    string // 到达站中文站名
 	PassengerTicketStr    string
 	OldPassengerTicketStr string
+	Student               bool // 是否购买学生票
 }
 
 // AutoSubmitOrder 自动提交订单请求，用于候补票/刷票
@@ -62,7 +62,7 @@ type AutoSubmitOrderRequest struct {
 // 旧乘客信息包含以下内容，用英文逗号 , 隔开，每个乘客之间用下划线 _ 隔开:
 // 乘客姓名,乘客证件类型,乘客证件号码,乘客类型
 // 乘客类型与 getpassengerTicketsForAutoSubmit 中的 车票类型 意义一致（参照 bv 函数）
-func AutoSubmitOrder(jar *cookiejar.Jar, request *AutoSubmitOrderRequest) (orderID string, err error) {
+func AutoSubmitOrder(sess *session.Session, request *AutoSubmitOrderRequest) (orderID string, err error) {
 	const (
 		url0    = "https://%s/otn/confirmPassenger/autoSubmitOrderRequest"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -71,7 +71,7 @@ func AutoSubmitOrder(jar *cookiejar.Jar, request *AutoSubmitOrderRequest) (order
 	payload.Add("secretStr", request.SecretStr)
 	payload.Add("train_date", request.TrainDate)
 	payload.Add("tour_flag", "dc")
-	payload.Add("purpose_codes", common.PassengerTypeToPurposeCodes())
+	payload.Add("purpose_codes", common.PassengerTypeToPurposeCodes(request.Student, sess.LoginIsDisable))
 	payload.Add("query_from_station_name", request.QueryFromStationName)
 	payload.Add("query_to_station_name", request.QueryToStationName)
 	payload.Add("_json_att", "")
@@ -81,7 +81,7 @@ func AutoSubmitOrder(jar *cookiejar.Jar, request *AutoSubmitOrderRequest) (order
 	payload.Add("oldPassengerStr", request.OldPassengerTicketStr)
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -91,7 +91,7 @@ func AutoSubmitOrder(jar *cookiejar.Jar, request *AutoSubmitOrderRequest) (order
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("自动提交订单错误", zap.Error(err))
 
@@ -4,8 +4,8 @@ import (
 	"fmt"
 	"gogo12306/common"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"gogo12306/worker"
-	"net/http/cookiejar"
 	"strings"
 
 	"go.uber.org/zap"
@@ -17,7 +17,7 @@ func getPassengerTicketsForAutoSubmit(passengers common.PassengerTicketInfos) st
 		arr = append(arr, fmt.Sprintf("%s,%d,%d,%s,%s,%s,%s,N,%s",
 			passenger.SeatType,
 			passenger.BedPos,
-			passenger.PassengerType,
+			passenger.TicketType,
 			passenger.PassengerName,
 			passenger.IDTypeCode,
 			passenger.IDNumber,
@@ -42,19 +42,20 @@ func getOldPassengersForAutoSubmit(passengers common.PassengerTicketInfos) (ret
 	return
 }
 
-func DoAutoOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
+func DoAutoOrder(sess *session.Session, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
 	startDate string, passengers common.PassengerTicketInfos) (orderID string, err error) {
 	var (
 		passengerTicketStr    string = getPassengerTicketsForAutoSubmit(passengers)
 		oldPassengerTicketStr string = getOldPassengersForAutoSubmit(passengers)
 	)
-	if orderID, err = AutoSubmitOrder(jar, &AutoSubmitOrderRequest{
+	if orderID, err = AutoSubmitOrder(sess, &AutoSubmitOrderRequest{
 		SecretStr:             leftTicketInfo.SecretStr,
 		TrainDate:             startDate,
-		QueryFromStationName:  task.From,
-		QueryToStationName:    task.To,
+		QueryFromStationName:  leftTicketInfo.From,
+		QueryToStationName:    leftTicketInfo.To,
 		PassengerTicketStr:    passengerTicketStr,
 		OldPassengerTicketStr: oldPassengerTicketStr,
+		Student:               task.Student,
 	}); err != nil {
 		logger.Error("自动下单失败", zap.Error(err))
 
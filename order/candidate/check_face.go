@@ -8,14 +8,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
 	"gogo12306/login"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -26,7 +25,7 @@ type CheckFaceRequest struct {
 
 // CheckFace 获取人脸识别核验状态（原 12306 API 为 afterNate/chechFace，拼写错误？）
 // https://kyfw.12306.cn/otn/resources/merged/queryLeftTicket_end_js.js 关键词: an 函数
-func CheckFace(jar *cookiejar.Jar, request *CheckFaceRequest) (err error) {
+func CheckFace(sess *session.Session, request *CheckFaceRequest) (err error) {
 	const (
 		url0    = "https://%s/otn/afterNate/chechFace"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -37,7 +36,7 @@ func CheckFace(jar *cookiejar.Jar, request *CheckFaceRequest) (err error) {
 	payload.Add("_json_att", "")
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 	req.Header.Add("If-Modified-Since", "0")
@@ -47,7 +46,7 @@ func CheckFace(jar *cookiejar.Jar, request *CheckFaceRequest) (err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取人脸识别核验状态错误", zap.Error(err))
 
@@ -85,7 +84,7 @@ func CheckFace(jar *cookiejar.Jar, request *CheckFaceRequest) (err error) {
 		return errors.New(strings.Join(response.Messages, ""))
 	} else if !response.Data.LoginFlag {
 		logger.Error("获取人脸识别核验状态结果: 未登录不能候补", zap.Strings("错误消息", response.Messages))
-		login.Login(jar)
+		login.Login(sess)
 
 		return errors.New(strings.Join(response.Messages, ""))
 	} else if !response.Data.FaceFlag { // 未通过人脸识别，以下进入 bE 函数的逻辑
@@ -101,7 +100,7 @@ func CheckFace(jar *cookiejar.Jar, request *CheckFaceRequest) (err error) {
 		case "04", "14":
 			if response.Data.IsShowQRCode {
 				// 下载人脸识别流程二维码并让用户扫码完成核验
-				if err = GetCheckFaceQRCode(jar, &GetCheckFaceQRCodeRequest{
+				if err = GetCheckFaceQRCode(sess, &GetCheckFaceQRCodeRequest{
 					AuthType:    "queueOrder",
 					RiskChannel: "HB",
 					CheckUrl:    "/afterNateQRCode/getClickScanStatus",
@@ -133,7 +132,7 @@ type GetCheckFaceQRCodeRequest struct {
 
 // GetCheckFaceQRCode 获取人脸识别流程二维码
 // 12306 源码里的 get_QRcodeAjax 函数
-func GetCheckFaceQRCode(jar *cookiejar.Jar, request *GetCheckFaceQRCodeRequest) (err error) {
+func GetCheckFaceQRCode(sess *session.Session, request *GetCheckFaceQRCodeRequest) (err error) {
 	const referer = ""
 	url0 := "https://%s/otn" + request.CheckUrl
 
@@ -143,7 +142,7 @@ func GetCheckFaceQRCode(jar *cookiejar.Jar, request *GetCheckFaceQRCodeRequest)
 	payload.Add("riskChannel", request.RiskChannel)
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -151,7 +150,7 @@ func GetCheckFaceQRCode(jar *cookiejar.Jar, request *GetCheckFaceQRCodeRequest)
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取人脸识别流程二维码错误", zap.Error(err))
 
@@ -2,11 +2,11 @@ package candidate
 
 import (
 	"fmt"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
 	"gogo12306/common"
+	"gogo12306/session"
 	"gogo12306/worker"
 )
 
@@ -59,7 +59,7 @@ func getConfirmHBSecret(passengers common.PassengerTicketInfos) (ret string) {
 		}
 
 		ret += fmt.Sprintf("%d#%s#%s#%s#%s#%d;",
-			passenger.PassengerType,
+			passenger.TicketType,
 			passenger.PassengerName,
 			passenger.IDTypeCode,
 			passenger.IDNumber,
@@ -80,40 +80,40 @@ func getCandidateTrains(trainNos []string, seatIndex int) (ret string) {
 	return
 }
 
-func DoCandidate(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
+func DoCandidate(sess *session.Session, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
 	seatIndex int, passengers common.PassengerTicketInfos) (info *CandidateInfo, err error) {
 	info = &CandidateInfo{}
 
 	var secretStr string = getCandidateSecretStr(leftTicketInfo.SecretStr, seatIndex)
 
-	if err = CheckFace(jar, &CheckFaceRequest{
+	if err = CheckFace(sess, &CheckFaceRequest{
 		SecretStr: secretStr,
 	}); err != nil {
 		return
 	}
 
 	var trainNos []string
-	if trainNos, info.Info, err = GetSuccessRate(jar, &GetSuccessRateRequest{
+	if trainNos, info.Info, err = GetSuccessRate(sess, &GetSuccessRateRequest{
 		SecretStr: strings.TrimSuffix(secretStr, "|"),
 	}); err != nil {
 		return
 	}
 
-	if err = SubmitOrder(jar, &SubmitOrderRequest{
+	if err = SubmitOrder(sess, &SubmitOrderRequest{
 		SecretStr: secretStr,
 	}); err != nil {
 		return
 	}
 
-	if info.Deadline, err = PassengerInitAPI(jar, &PassengerInitAPIRequest{}); err != nil {
+	if info.Deadline, err = PassengerInitAPI(sess, &PassengerInitAPIRequest{}); err != nil {
 		return
 	}
 
-	if err = GetQueueNum(jar, &GetQueueNumRequest{}); err != nil {
+	if err = GetQueueNum(sess, &GetQueueNumRequest{}); err != nil {
 		return
 	}
 
-	if info.ReserveNo, err = ConfirmHB(jar, &ConfirmHBRequest{
+	if info.ReserveNo, err = ConfirmHB(sess, &ConfirmHBRequest{
 		PassengerInfo:  getConfirmHBSecret(passengers),
 		CandidateTrain: getCandidateTrains(trainNos, seatIndex),
 		Deadline:       task.CandidateDeadline,
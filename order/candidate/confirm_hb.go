@@ -6,14 +6,13 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -25,7 +24,7 @@ type ConfirmHBRequest struct {
 }
 
 // ConfirmHB 确认候补订单
-func ConfirmHB(jar *cookiejar.Jar, request *ConfirmHBRequest) (reserveNo string, err error) {
+func ConfirmHB(sess *session.Session, request *ConfirmHBRequest) (reserveNo string, err error) {
 	const (
 		url0    = "https://%s/otn/afterNate/confirmHB"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -43,7 +42,7 @@ func ConfirmHB(jar *cookiejar.Jar, request *ConfirmHBRequest) (reserveNo string,
 	payload.Add("realize_limit_time_diff", strconv.Itoa(request.Deadline))
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -51,7 +50,7 @@ func ConfirmHB(jar *cookiejar.Jar, request *ConfirmHBRequest) (reserveNo string,
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("确认候补订单错误", zap.Error(err))
 
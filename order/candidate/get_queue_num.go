@@ -6,12 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -20,14 +19,14 @@ type GetQueueNumRequest struct {
 }
 
 // GetQueueNum 获取候补人数信息
-func GetQueueNum(jar *cookiejar.Jar, request *GetQueueNumRequest) (err error) {
+func GetQueueNum(sess *session.Session, request *GetQueueNumRequest) (err error) {
 	const (
 		url0    = "https://%s/otn/afterNate/getQueueNum"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
 	)
 
 	buf := bytes.NewBuffer([]byte{})
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -35,7 +34,7 @@ func GetQueueNum(jar *cookiejar.Jar, request *GetQueueNumRequest) (err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取候补人数信息错误", zap.Error(err))
 
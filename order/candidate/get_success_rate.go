@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -22,7 +21,7 @@ type GetSuccessRateRequest struct {
 }
 
 // GetSuccessRate 获取人脸识别核验后的成功信息
-func GetSuccessRate(jar *cookiejar.Jar, request *GetSuccessRateRequest) (trainNos []string, info string, err error) {
+func GetSuccessRate(sess *session.Session, request *GetSuccessRateRequest) (trainNos []string, info string, err error) {
 	const (
 		url0    = "https://%s/otn/afterNate/getSuccessRate"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -33,7 +32,7 @@ func GetSuccessRate(jar *cookiejar.Jar, request *GetSuccessRateRequest) (trainNo
 	payload.Add("_json_att", "")
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -41,7 +40,7 @@ func GetSuccessRate(jar *cookiejar.Jar, request *GetSuccessRateRequest) (trainNo
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取人脸识别核验后的成功信息错误", zap.Error(err))
 
@@ -6,12 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -20,14 +19,14 @@ type QueryQueueRequest struct {
 }
 
 // QueryQueue 查询候补结果
-func QueryQueue(jar *cookiejar.Jar, request *QueryQueueRequest) (err error) {
+func QueryQueue(sess *session.Session, request *QueryQueueRequest) (err error) {
 	const (
 		url0    = "https://%s/otn/afterNate/queryQueue"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
 	)
 
 	buf := bytes.NewBuffer([]byte{})
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -35,7 +34,7 @@ func QueryQueue(jar *cookiejar.Jar, request *QueryQueueRequest) (err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("查询候补结果错误", zap.Error(err))
 
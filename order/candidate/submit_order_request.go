@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -22,7 +21,7 @@ type SubmitOrderRequest struct {
 }
 
 // SubmitOrder 提交候补订单请求
-func SubmitOrder(jar *cookiejar.Jar, request *SubmitOrderRequest) (err error) {
+func SubmitOrder(sess *session.Session, request *SubmitOrderRequest) (err error) {
 	const (
 		url0    = "https://%s/otn/afterNate/submitOrderRequest"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -33,7 +32,7 @@ func SubmitOrder(jar *cookiejar.Jar, request *SubmitOrderRequest) (err error) {
 	payload.Add("_json_att", "")
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -41,7 +40,7 @@ func SubmitOrder(jar *cookiejar.Jar, request *SubmitOrderRequest) (err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("提交候补订单请求错误", zap.Error(err))
 
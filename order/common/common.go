@@ -1,20 +1,20 @@
 package common
 
-var LoginIsDisable bool
-
 // https://kyfw.12306.cn/otn/resources/merged/queryLeftTicket_end_js.js cI() 函数
-func PassengerTypeToPurposeCodes() string {
-	// if 点选 “学生票” {
-	// 	if LoginIsDisable {
-	// 		return "0X1C"
-	// 	} else {
-	// 		return "0X00"
-	// 	}
-	// } else {
-	if LoginIsDisable {
-		return "1C"
+// student: 是否点选 “学生票”
+// loginIsDisable: 余票查询页面的 login_isDisable 是否为 Y
+func PassengerTypeToPurposeCodes(student, loginIsDisable bool) string {
+	if student {
+		if loginIsDisable {
+			return "0X1C"
+		} else {
+			return "0X00"
+		}
 	} else {
-		return "ADULT"
+		if loginIsDisable {
+			return "1C"
+		} else {
+			return "ADULT"
+		}
 	}
-	// }
 }
@@ -3,7 +3,6 @@ package order
 import (
 	"errors"
 	"fmt"
-	"net/http/cookiejar"
 	"time"
 
 	"gogo12306/common"
@@ -12,14 +11,15 @@ import (
 	"gogo12306/order/auto"
 	"gogo12306/order/candidate"
 	"gogo12306/order/normal"
+	"gogo12306/session"
 	"gogo12306/worker"
 
 	"go.uber.org/zap"
 )
 
-func DoOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
+func DoOrder(sess *session.Session, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
 	startDate, trainCode string, seatIndex int, passengers common.PassengerTicketInfos) (err error) {
-	// if err = login.CheckAndRelogin(jar); err != nil {
+	// if err = login.CheckAndRelogin(sess); err != nil {
 	// 	return
 	// }
 
@@ -36,18 +36,17 @@ func DoOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common.LeftT
 
 	var orderID string
 	if !leftTicketInfo.CanWebBuy && leftTicketInfo.CandidateFlag { // 可以候补
-		if task.AllowCandidate { // 抢候补票
+		if task.AllowCandidate && task.TourFlag != worker.TourFlagBack { // 抢候补票，返程票不能候补
 			var info *candidate.CandidateInfo
-			if info, err = candidate.DoCandidate(jar, task, leftTicketInfo, seatIndex, passengers); err != nil {
+			if info, err = candidate.DoCandidate(sess, task, leftTicketInfo, seatIndex, passengers); err != nil {
 				return
 			}
 
-			notifier.Broadcast(fmt.Sprintf("GOGO12306 于 %s 成功帮您抢到 %s 至 %s，出发时间 %s %s，车次 %s 的候补车票，截止兑换日期时间为 %s，目前%s，订单号为 %s，请尽快登陆 12306 网站或使用 12306 APP 完成候补支付",
-				time.Now().Format(time.RFC3339), task.From, task.To, startDate, leftTicketInfo.StartTime, leftTicketInfo.TrainCode, info.Deadline, info.Info, info.ReserveNo,
+			notifier.Broadcast(&sess.Cfg.Notifier, fmt.Sprintf("GOGO12306 于 %s 成功帮您抢到 %s 至 %s，出发时间 %s %s，车次 %s 的候补车票，截止兑换日期时间为 %s，目前%s，订单号为 %s，请尽快登陆 12306 网站或使用 12306 APP 完成候补支付",
+				time.Now().Format(time.RFC3339), leftTicketInfo.From, leftTicketInfo.To, startDate, leftTicketInfo.StartTime, leftTicketInfo.TrainCode, info.Deadline, info.Info, info.ReserveNo,
 			))
 
 			// TODO 候补完成后继续尝试抢其他车次的票
-			task.Done <- struct{}{}
 			return
 		} else { // 不接受候补
 			logger.Debug("由于设置不接受候补，忽略此车次和座席...",
@@ -59,20 +58,33 @@ func DoOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common.LeftT
 		}
 	} else { // 直接购票
 		if task.OrderType == 1 { // 普通购票，流程复杂，耗时较长，但成功率高
-			if orderID, err = normal.DoNormalOrder(jar, task, leftTicketInfo, startDate, seatIndex, passengers); err != nil {
+			if orderID, err = normal.DoNormalOrder(sess, task, leftTicketInfo, startDate, seatIndex, passengers); err != nil {
 				return
 			}
 		} else if task.OrderType == 2 { // 自动捡漏下单，流程简单，但成功率不高不稳定
-			if orderID, err = auto.DoAutoOrder(jar, task, leftTicketInfo, startDate, passengers); err != nil {
+			if orderID, err = auto.DoAutoOrder(sess, task, leftTicketInfo, startDate, passengers); err != nil {
 				return
 			}
 		}
 	}
 
-	notifier.Broadcast(fmt.Sprintf("GOGO12306 于 %s 成功帮您抢到 %s 至 %s，出发时间 %s %s，车次 %s，乘客: %s 的车票，订单号为 %s，请尽快登陆 12306 网站或使用 12306 APP 完成购票支付",
-		time.Now().Format(time.RFC3339), task.From, task.To, startDate, leftTicketInfo.StartTime, leftTicketInfo.TrainCode, passengers.Names(), orderID,
-	))
+	switch task.TourFlag {
+	case worker.TourFlagGo:
+		notifier.Broadcast(&sess.Cfg.Notifier, fmt.Sprintf("GOGO12306 于 %s 成功帮您抢到 %s 至 %s，出发时间 %s %s，车次 %s，乘客: %s 的往返票去程车票，订单号为 %s，正在继续抢返程车票，请在支付期限内登陆 12306 网站或使用 12306 APP 完成购票支付",
+			time.Now().Format(time.RFC3339), leftTicketInfo.From, leftTicketInfo.To, startDate, leftTicketInfo.StartTime, leftTicketInfo.TrainCode, passengers.Names(), orderID,
+		))
 
-	task.Done <- struct{}{}
+	case worker.TourFlagBack:
+		notifier.Broadcast(&sess.Cfg.Notifier, fmt.Sprintf("GOGO12306 于 %s 成功帮您抢到 %s 至 %s，出发时间 %s %s，车次 %s，乘客: %s 的往返票返程车票，订单号为 %s，请尽快登陆 12306 网站或使用 12306 APP 完成去程和返程车票的支付",
+			time.Now().Format(time.RFC3339), leftTicketInfo.From, leftTicketInfo.To, startDate, leftTicketInfo.StartTime, leftTicketInfo.TrainCode, passengers.Names(), orderID,
+		))
+
+	default:
+		notifier.Broadcast(&sess.Cfg.Notifier, fmt.Sprintf("GOGO12306 于 %s 成功帮您抢到 %s 至 %s，出发时间 %s %s，车次 %s，乘客: %s 的车票，订单号为 %s，请尽快登陆 12306 网站或使用 12306 APP 完成购票支付",
+			time.Now().Format(time.RFC3339), leftTicketInfo.From, leftTicketInfo.To, startDate, leftTicketInfo.StartTime, leftTicketInfo.TrainCode, passengers.Names(), orderID,
+		))
+	}
+
+	// 成功后由调用方通知任务结束，这里不能重复发送，否则会阻塞
 	return
 }
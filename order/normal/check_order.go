@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -20,33 +19,34 @@ import (
 type CheckOrderRequest struct {
 	PassengerTicketStr    string
 	OldPassengerTicketStr string
+	TourFlag              string // 行程类型，留空为单程
 }
 
 // CheckOrder 下单成功后检查订单信息
 // ifShowPassCode: 如果此字段存在，并且值为 Y，则需要做验证码识别
 // ifShowPassCodeTime: 验证码识别完之前要等待的毫秒数
-func CheckOrder(jar *cookiejar.Jar, request *CheckOrderRequest) (ifShowPassCode bool, ifShowPassCodeTime int, err error) {
+func CheckOrder(sess *session.Session, request *CheckOrderRequest) (ifShowPassCode bool, ifShowPassCodeTime int, err error) {
 	const (
-		url0    = "https://%s/otn/confirmPassenger/checkOrderInfo"
-		referer = "https://kyfw.12306.cn/otn/confirmPassenger/initDc"
+		url0 = "https://%s/otn/confirmPassenger/checkOrderInfo"
 	)
+	referer := getReferer(request.TourFlag)
 
 	payload := &url.Values{}
 	payload.Add("cancel_flag", "2")
 	payload.Add("bed_level_order_num", "000000000000000000000000000000")
 	payload.Add("passengerTicketStr", request.PassengerTicketStr)
 	payload.Add("oldPassengerStr", request.OldPassengerTicketStr)
-	payload.Add("tour_flag", "dc")
+	payload.Add("tour_flag", getTourFlag(request.TourFlag))
 	payload.Add("randCode", "")
 	payload.Add("whatsSelect", "1")
 	payload.Add("sessionId", "")
 	payload.Add("sig", "")
 	payload.Add("scene", "nc_login")
 	payload.Add("_json_att", "")
-	payload.Add("REPEAT_SUBMIT_TOKEN", globalRepeatSubmitToken)
+	payload.Add("REPEAT_SUBMIT_TOKEN", sess.RepeatSubmitToken())
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -54,7 +54,7 @@ func CheckOrder(jar *cookiejar.Jar, request *CheckOrderRequest) (ifShowPassCode
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("检查订单信息错误", zap.Error(err))
 
@@ -3,20 +3,18 @@ package normal
 import (
 	"errors"
 	"fmt"
-	"net/http/cookiejar"
 	"strings"
 	"time"
 
+	"gogo12306/captcha"
 	"gogo12306/common"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"gogo12306/worker"
 
 	"go.uber.org/zap"
 )
 
-var globalRepeatSubmitToken string
-var ticketInfoForPassengerForm map[string]interface{}
-
 // 以下都是通过 12306 官网源码解析得到
 // https://kyfw.12306.cn/otn/resources/merged/queryLeftTicket_end_js.js
 
@@ -33,13 +31,46 @@ var ticketInfoForPassengerForm map[string]interface{}
 // 1  - 硬座
 // WZ - 无座
 
+// tourPath 行程类型对应的下单页面、确认排队接口和获取下单结果接口
+type tourPath struct {
+	init    string
+	confirm string
+	result  string
+}
+
+// https://kyfw.12306.cn/otn/resources/merged/passengerInfo_js.js 搜关键字：tour_flag
+var tourPaths = map[string]tourPath{
+	worker.TourFlagSingle: {"initDc", "confirmSingleForQueue", "resultOrderForDcQueue"},
+	worker.TourFlagGo:     {"initWc", "confirmGoForQueue", "resultOrderForWcQueue"},
+	worker.TourFlagBack:   {"initFc", "confirmBackForQueue", "resultOrderForFcQueue"},
+}
+
+// getTourFlag 行程类型，留空为单程
+func getTourFlag(tourFlag string) string {
+	if _, ok := tourPaths[tourFlag]; !ok {
+		return worker.TourFlagSingle
+	}
+
+	return tourFlag
+}
+
+// getTourPath 行程类型对应的接口，留空为单程
+func getTourPath(tourFlag string) tourPath {
+	return tourPaths[getTourFlag(tourFlag)]
+}
+
+// getReferer 下单页面的地址，下单流程的接口都以此为 Referer
+func getReferer(tourFlag string) string {
+	return "https://kyfw.12306.cn/otn/confirmPassenger/" + getTourPath(tourFlag).init
+}
+
 func getPassengerTickets(passengers common.PassengerTicketInfos) string {
 	var arr []string
 	for _, passenger := range passengers {
 		arr = append(arr, fmt.Sprintf("%s,%d,%d,%s,%s,%s,%s,N,%s",
 			passenger.SeatType,
 			passenger.BedPos,
-			passenger.PassengerType,
+			passenger.TicketType,
 			passenger.PassengerName,
 			passenger.IDTypeCode,
 			passenger.IDNumber,
@@ -64,18 +95,54 @@ func getOldPassengers(passengers common.PassengerTicketInfos) (ret string) {
 	return
 }
 
-func DoNormalOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
+// backTrainDate 往返票的返程日期，单程留空
+// 返程下单时为正在下单的日期；去程下单时为不早于去程日期的最早的返程日期
+func backTrainDate(task *worker.Task, startDate string) string {
+	switch task.TourFlag {
+	case worker.TourFlagBack:
+		return startDate
+	case worker.TourFlagGo:
+		if task.Back == nil {
+			return startDate
+		}
+
+		// 日期格式为 YYYY-MM-DD，可以直接按字符串比较
+		back := ""
+		for _, date := range task.Back.StartDates {
+			if date >= startDate && (back == "" || date < back) {
+				back = date
+			}
+		}
+
+		if back == "" {
+			return startDate
+		}
+
+		return back
+	}
+
+	return ""
+}
+
+func DoNormalOrder(sess *session.Session, task *worker.Task, leftTicketInfo *common.LeftTicketInfo,
 	startDate string, seatIndex int, passengers common.PassengerTicketInfos) (orderID string, err error) {
-	if err = SubmitOrder(jar, &SubmitOrderRequest{
+	// 下单页面的 token 保存在会话中，同一账号的多个任务不能同时下单
+	sess.LockOrder()
+	defer sess.UnlockOrder()
+
+	if err = SubmitOrder(sess, &SubmitOrderRequest{
 		SecretStr:            leftTicketInfo.SecretStr,
 		TrainDate:            startDate,
-		QueryFromStationName: task.From, // 注意使用中文站名
-		QueryToStationName:   task.To,   // 注意使用中文站名
+		QueryFromStationName: leftTicketInfo.From, // 注意使用中文站名
+		QueryToStationName:   leftTicketInfo.To,   // 注意使用中文站名
+		Student:              task.Student,
+		TourFlag:             task.TourFlag,
+		BackTrainDate:        backTrainDate(task, startDate),
 	}); err != nil {
 		return
 	}
 
-	if err = InitToken(jar); err != nil {
+	if err = InitToken(sess, task.TourFlag); err != nil {
 		return
 	}
 
@@ -85,40 +152,53 @@ func DoNormalOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common
 		passengerTicketStr    string = getPassengerTickets(passengers)
 		oldPassengerTicketStr string = getOldPassengers(passengers)
 	)
-	if ifShowPassCode, ifShowPassCodeTime, err = CheckOrder(jar, &CheckOrderRequest{
+	if ifShowPassCode, ifShowPassCodeTime, err = CheckOrder(sess, &CheckOrderRequest{
 		PassengerTicketStr:    passengerTicketStr,
 		OldPassengerTicketStr: oldPassengerTicketStr,
+		TourFlag:              task.TourFlag,
 	}); err != nil {
 		return
 	}
 
 	logger.Debug("是否需要验证码", zap.Bool("ifShowPassCode", ifShowPassCode), zap.Int("ifShowPassCodeTime", ifShowPassCodeTime))
 
-	if err = GetQueueCountResult(jar, &GetQueueCountRequest{
+	if err = GetQueueCountResult(sess, &GetQueueCountRequest{
 		TrainDate:            startDate,
 		TrainNumber:          leftTicketInfo.TrainNumber,
 		TrainCode:            leftTicketInfo.TrainCode,
 		SeatType:             common.SeatIndexToSeatType(seatIndex),
-		QueryFromStationName: task.FromTelegramCode,
-		QueryToStationName:   task.ToTelegramCode,
+		QueryFromStationName: leftTicketInfo.FromTelegramCode,
+		QueryToStationName:   leftTicketInfo.ToTelegramCode,
 		LeftTicketStr:        leftTicketInfo.LeftTicketStr,
+		TourFlag:             task.TourFlag,
 	}); err != nil {
 		return
 	}
 
+	var randCode string
 	if ifShowPassCode {
-		// TODO 验证码识别
+		// 提交订单前需要等待的时间包含识别验证码的时间
+		deadline := time.Now().Add(time.Millisecond * time.Duration(ifShowPassCodeTime))
 
-		if ifShowPassCodeTime > 0 {
-			time.Sleep(time.Millisecond * time.Duration(ifShowPassCodeTime))
+		var solver captcha.Solver
+		if solver, err = captcha.NewSolver(sess.Login); err != nil {
+			return
 		}
+
+		if randCode, err = captcha.SolveOrder(sess, solver, getReferer(task.TourFlag)); err != nil {
+			return
+		}
+
+		time.Sleep(time.Until(deadline))
 	}
 
-	if err = ConfirmSingleForQueue(jar, &ConfirmSingleForQueueRequest{
+	if err = ConfirmSingleForQueue(sess, &ConfirmSingleForQueueRequest{
 		PassengerTicketStr:    passengerTicketStr,
 		OldPassengerTicketStr: oldPassengerTicketStr,
+		RandCode:              randCode,
 		ChooseSeats:           task.ChooseSeats,
 		SeatDetailType:        task.SeatDetailType,
+		TourFlag:              task.TourFlag,
 	}); err != nil {
 		return
 	}
@@ -131,7 +211,7 @@ func DoNormalOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common
 		retries++
 		time.Sleep(time.Second * 3)
 
-		if orderID, err = QueryOrderWaitTime(jar); err != nil {
+		if orderID, err = QueryOrderWaitTime(sess, task.TourFlag); err != nil {
 			return
 		} else if orderID != "" {
 			break
@@ -146,8 +226,9 @@ func DoNormalOrder(jar *cookiejar.Jar, task *worker.Task, leftTicketInfo *common
 		return "", errors.New("orderID empty")
 	}
 
-	if err = ResultOrderForDcQueue(jar, &ResultOrderForDcQueueRequest{
-		OrderID: orderID,
+	if err = ResultOrderForDcQueue(sess, &ResultOrderForDcQueueRequest{
+		OrderID:  orderID,
+		TourFlag: task.TourFlag,
 	}); err != nil {
 		return
 	}
@@ -6,13 +6,12 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -20,29 +19,33 @@ import (
 type ConfirmSingleForQueueRequest struct {
 	PassengerTicketStr    string
 	OldPassengerTicketStr string
+	RandCode              string // 提交订单验证码的答案坐标，不需要验证码时留空
 	ChooseSeats           []string
 	SeatDetailType        []string
+	TourFlag              string // 行程类型，留空为单程，往返的去程和返程分别使用 confirmGoForQueue 和 confirmBackForQueue
 }
 
 // ConfirmSingleForQueue 确认排队情况
-func ConfirmSingleForQueue(jar *cookiejar.Jar, request *ConfirmSingleForQueueRequest) (err error) {
+func ConfirmSingleForQueue(sess *session.Session, request *ConfirmSingleForQueueRequest) (err error) {
 	const (
-		url0    = "https://%s/otn/confirmPassenger/confirmSingleForQueue"
-		referer = "https://kyfw.12306.cn/otn/confirmPassenger/initDc"
+		url0 = "https://%s/otn/confirmPassenger/%s"
 	)
+	referer := getReferer(request.TourFlag)
+
+	form := sess.TicketInfoForPassengerForm()
 
 	payload := &url.Values{}
 	payload.Add("passengerTicketStr", request.PassengerTicketStr)
 	payload.Add("oldPassengerStr", request.OldPassengerTicketStr)
-	payload.Add("randCode", "")
-	payload.Add("purpose_codes", ticketInfoForPassengerForm["purpose_codes"].(string))
-	payload.Add("key_check_isChange", ticketInfoForPassengerForm["key_check_isChange"].(string))
+	payload.Add("randCode", request.RandCode)
+	payload.Add("purpose_codes", form["purpose_codes"].(string))
+	payload.Add("key_check_isChange", form["key_check_isChange"].(string))
 
 	// payload.Add("leftTicket", request.LeftTicketStr)
-	payload.Add("leftTicketStr", ticketInfoForPassengerForm["leftTicketStr"].(string))
-	// payload.Add("leftTicket", ticketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["ypInfoDetail"].(string))
+	payload.Add("leftTicketStr", form["leftTicketStr"].(string))
+	// payload.Add("leftTicket", sess.TicketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["ypInfoDetail"].(string))
 
-	payload.Add("train_location", ticketInfoForPassengerForm["train_location"].(string))
+	payload.Add("train_location", form["train_location"].(string))
 
 	payload.Add("choose_seats", strings.Join(request.ChooseSeats, ""))
 	if len(request.SeatDetailType) > 0 {
@@ -60,10 +63,10 @@ func ConfirmSingleForQueue(jar *cookiejar.Jar, request *ConfirmSingleForQueueReq
 	payload.Add("dwAll", "N")     // TODO
 
 	payload.Add("_json_att", "")
-	payload.Add("REPEAT_SUBMIT_TOKEN", globalRepeatSubmitToken)
+	payload.Add("REPEAT_SUBMIT_TOKEN", sess.RepeatSubmitToken())
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN(), getTourPath(request.TourFlag).confirm), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -71,7 +74,7 @@ func ConfirmSingleForQueue(jar *cookiejar.Jar, request *ConfirmSingleForQueueReq
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("确认排队情况错误", zap.Error(err))
 
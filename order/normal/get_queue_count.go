@@ -6,15 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -27,14 +26,15 @@ type GetQueueCountRequest struct {
 	QueryFromStationName string // 出发站电报码
 	QueryToStationName   string // 到达站电报码
 	LeftTicketStr        string // 余票密钥串
+	TourFlag             string // 行程类型，留空为单程
 }
 
 // GetQueueCountResult 获取排队信息
-func GetQueueCountResult(jar *cookiejar.Jar, request *GetQueueCountRequest) (err error) {
+func GetQueueCountResult(sess *session.Session, request *GetQueueCountRequest) (err error) {
 	const (
-		url0    = "https://%s/otn/confirmPassenger/getQueueCount"
-		referer = "https://kyfw.12306.cn/otn/confirmPassenger/initDc"
+		url0 = "https://%s/otn/confirmPassenger/getQueueCount"
 	)
+	referer := getReferer(request.TourFlag)
 
 	var trainDate time.Time
 	if trainDate, err = time.Parse("2006-01-02", request.TrainDate); err != nil {
@@ -43,32 +43,34 @@ func GetQueueCountResult(jar *cookiejar.Jar, request *GetQueueCountRequest) (err
 		return
 	}
 
+	form := sess.TicketInfoForPassengerForm()
+
 	payload := &url.Values{}
 	payload.Add("train_date", trainDate.Format("Mon Jan 01 2006 00:00:00 GMT-0700 (中国标准时间)"))
 
 	payload.Add("train_no", request.TrainNumber)
 	payload.Add("stationTrainCode", request.TrainCode)
-	// payload.Add("train_no", ticketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["train_no"].(string))
-	// payload.Add("stationTrainCode", ticketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["station_train_code"].(string))
+	// payload.Add("train_no", sess.TicketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["train_no"].(string))
+	// payload.Add("stationTrainCode", sess.TicketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["station_train_code"].(string))
 
 	payload.Add("seatType", request.SeatType)
 
 	payload.Add("fromStationTelecode", request.QueryFromStationName)
 	payload.Add("toStationTelecode", request.QueryToStationName)
-	// payload.Add("fromStationTelecode", ticketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["from_station"].(string))
-	// payload.Add("toStationTelecode", ticketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["to_station"].(string))
+	// payload.Add("fromStationTelecode", sess.TicketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["from_station"].(string))
+	// payload.Add("toStationTelecode", sess.TicketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["to_station"].(string))
 
 	// payload.Add("leftTicket", request.LeftTicketStr)
-	// payload.Add("leftTicket", ticketInfoForPassengerForm["leftTicketStr"].(string))
-	payload.Add("leftTicket", ticketInfoForPassengerForm["queryLeftTicketRequestDTO"].(map[string]interface{})["ypInfoDetail"].(string))
+	// payload.Add("leftTicket", sess.TicketInfoForPassengerForm["leftTicketStr"].(string))
+	payload.Add("leftTicket", form["queryLeftTicketRequestDTO"].(map[string]interface{})["ypInfoDetail"].(string))
 
-	payload.Add("purpose_codes", ticketInfoForPassengerForm["purpose_codes"].(string))
-	payload.Add("train_location", ticketInfoForPassengerForm["train_location"].(string))
+	payload.Add("purpose_codes", form["purpose_codes"].(string))
+	payload.Add("train_location", form["train_location"].(string))
 	payload.Add("_json_att", "")
-	payload.Add("REPEAT_SUBMIT_TOKEN", globalRepeatSubmitToken)
+	payload.Add("REPEAT_SUBMIT_TOKEN", sess.RepeatSubmitToken())
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -76,7 +78,7 @@ func GetQueueCountResult(jar *cookiejar.Jar, request *GetQueueCountRequest) (err
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取排队信息错误", zap.Error(err))
 
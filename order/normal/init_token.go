@@ -6,28 +6,28 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"regexp"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
 
-func InitToken(jar *cookiejar.Jar) (err error) {
+// InitToken 打开下单页面，获取下单用的 token 和车票信息，tourFlag 为行程类型
+func InitToken(sess *session.Session, tourFlag string) (err error) {
 	const (
-		url0    = "https://%s/otn/confirmPassenger/initDc"
+		url0    = "https://%s/otn/confirmPassenger/%s"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
 	)
 	payload := url.Values{}
 	payload.Add("_json_attr", "")
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN(), getTourPath(tourFlag).init), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -35,7 +35,7 @@ func InitToken(jar *cookiejar.Jar) (err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取下单页面信息错误", zap.Error(err))
 
@@ -43,7 +43,7 @@ func InitToken(jar *cookiejar.Jar) (err error) {
 	} else if statusCode != http.StatusOK {
 		logger.Error("获取下单页面信息失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
 
-		return errors.New("get init dc failure")
+		return errors.New("get init " + getTourFlag(tourFlag) + " failure")
 	}
 
 	var re1, re2 *regexp.Regexp
@@ -73,8 +73,6 @@ func InitToken(jar *cookiejar.Jar) (err error) {
 		return errors.New("regexp 2 match failure")
 	}
 
-	globalRepeatSubmitToken = string(body1[1])
-
 	var bodyStr2 /*, bodyStr3*/ string
 	if bodyStr2, err = url.QueryUnescape(string(body2[1])); err != nil {
 		logger.Error("获取下单页面信息，QueryUnescape JSON 2 失败", zap.ByteString("body2", body2[1]), zap.Error(err))
@@ -82,12 +80,15 @@ func InitToken(jar *cookiejar.Jar) (err error) {
 		return
 	}
 
+	var form map[string]interface{}
 	decoder2 := json.NewDecoder(bytes.NewReader([]byte(strings.ReplaceAll(bodyStr2, "'", "\""))))
-	if err = decoder2.Decode(&ticketInfoForPassengerForm); err != nil {
+	if err = decoder2.Decode(&form); err != nil {
 		logger.Error("获取下单页面信息，Decode JSON 2 失败", zap.ByteString("body2", body2[1]), zap.Error(err))
 
 		return errors.New("decode json 2 failure")
 	}
 
+	sess.SetOrderToken(string(body1[1]), form)
+
 	return
 }
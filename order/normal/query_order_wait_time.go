@@ -5,24 +5,23 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
 	"time"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
 
-// QueryOrderWaitTime 查询订单排队等待时间
-func QueryOrderWaitTime(jar *cookiejar.Jar) (orderID string, err error) {
+// QueryOrderWaitTime 查询订单排队等待时间，tourFlag 为行程类型
+func QueryOrderWaitTime(sess *session.Session, tourFlag string) (orderID string, err error) {
 	const (
-		url0    = "https://%s/otn/confirmPassenger/queryOrderWaitTime?random=%d&tourFlag=dc&_json_att=&REPEAT_SUBMIT_TOKEN=%s"
-		referer = "https://kyfw.12306.cn/otn/confirmPassenger/initDc"
+		url0 = "https://%s/otn/confirmPassenger/queryOrderWaitTime?random=%d&tourFlag=%s&_json_att=&REPEAT_SUBMIT_TOKEN=%s"
 	)
-	req, _ := http.NewRequest("GET", fmt.Sprintf(url0, cdn.GetCDN(), time.Now().UnixMilli(), globalRepeatSubmitToken), nil)
+	referer := getReferer(tourFlag)
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url0, sess.CDN.GetCDN(), time.Now().UnixMilli(), getTourFlag(tourFlag), sess.RepeatSubmitToken()), nil)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -30,7 +29,7 @@ func QueryOrderWaitTime(jar *cookiejar.Jar) (orderID string, err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("查询订单排队等待时间错误", zap.Error(err))
 
@@ -6,34 +6,34 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
 
 type ResultOrderForDcQueueRequest struct {
-	OrderID string
+	OrderID  string
+	TourFlag string // 行程类型，留空为单程，往返的去程和返程分别使用 resultOrderForWcQueue 和 resultOrderForFcQueue
 }
 
 // ResultOrderForDcQueue 获取下单最后的结果
-func ResultOrderForDcQueue(jar *cookiejar.Jar, request *ResultOrderForDcQueueRequest) (err error) {
+func ResultOrderForDcQueue(sess *session.Session, request *ResultOrderForDcQueueRequest) (err error) {
 	const (
-		url0    = "https://%s/otn/confirmPassenger/resultOrderForDcQueue"
-		referer = "https://kyfw.12306.cn/otn/confirmPassenger/initDc"
+		url0 = "https://%s/otn/confirmPassenger/%s"
 	)
+	referer := getReferer(request.TourFlag)
 	payload := url.Values{}
 	payload.Add("orderSequence_no", request.OrderID)
 	payload.Add("_json_att", "")
-	payload.Add("REPEAT_SUBMIT_TOKEN", globalRepeatSubmitToken)
+	payload.Add("REPEAT_SUBMIT_TOKEN", sess.RepeatSubmitToken())
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN(), getTourPath(request.TourFlag).result), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -41,7 +41,7 @@ func ResultOrderForDcQueue(jar *cookiejar.Jar, request *ResultOrderForDcQueueReq
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("获取下单最后的结果错误", zap.Error(err))
 
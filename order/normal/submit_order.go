@@ -6,15 +6,14 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
-	"net/http/cookiejar"
 	"net/url"
 	"strings"
 	"time"
 
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
 	"gogo12306/order/common"
+	"gogo12306/session"
 
 	"go.uber.org/zap"
 )
@@ -24,10 +23,13 @@ type SubmitOrderRequest struct {
 	TrainDate            string // 出发日期
 	QueryFromStationName string // 出发站中文站名
 	QueryToStationName   string // 到达站中文站名
+	Student              bool   // 是否购买学生票
+	TourFlag             string // 行程类型，留空为单程
+	BackTrainDate        string // 往返票的返程日期，留空为当天
 }
 
 // SubmitOrder 一般下单请求，用于普通购票
-func SubmitOrder(jar *cookiejar.Jar, request *SubmitOrderRequest) (err error) {
+func SubmitOrder(sess *session.Session, request *SubmitOrderRequest) (err error) {
 	const (
 		url0    = "https://%s/otn/leftTicket/submitOrderRequest"
 		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
@@ -35,15 +37,20 @@ func SubmitOrder(jar *cookiejar.Jar, request *SubmitOrderRequest) (err error) {
 	payload := url.Values{}
 	payload.Add("secretStr", request.SecretStr)
 	payload.Add("train_date", request.TrainDate)
-	payload.Add("back_train_date", time.Now().Format("2006-01-02")) // 返程日期，貌似可以是任意日期
-	payload.Add("tour_flag", "dc")                                  // dc: 单程
-	payload.Add("purpose_codes", common.PassengerTypeToPurposeCodes())
+	// 返程日期，单程时貌似可以是任意日期
+	backTrainDate := request.BackTrainDate
+	if backTrainDate == "" {
+		backTrainDate = time.Now().Format("2006-01-02")
+	}
+	payload.Add("back_train_date", backTrainDate)
+	payload.Add("tour_flag", getTourFlag(request.TourFlag)) // dc: 单程，wc: 往返的去程，fc: 往返的返程
+	payload.Add("purpose_codes", common.PassengerTypeToPurposeCodes(request.Student, sess.LoginIsDisable))
 	payload.Add("query_from_station_name", request.QueryFromStationName) // 出发站中文站名
 	payload.Add("query_to_station_name", request.QueryToStationName)     // 到达站中文站名
 	payload.Add("undefined", "")
 
 	buf := bytes.NewBuffer([]byte(payload.Encode()))
-	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, cdn.GetCDN()), buf)
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -51,7 +58,7 @@ func SubmitOrder(jar *cookiejar.Jar, request *SubmitOrderRequest) (err error) {
 		body       []byte
 		statusCode int
 	)
-	body, statusCode, err = httpcli.DoHttp(req, jar)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
 	if err != nil {
 		logger.Error("提交订单错误", zap.Error(err))
 
@@ -0,0 +1,186 @@
+package order
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gogo12306/httpcli"
+	"gogo12306/logger"
+	"gogo12306/session"
+
+	"go.uber.org/zap"
+)
+
+type OrderStationTrain struct {
+	TrainCode       string `json:"station_train_code"`
+	FromStationName string `json:"from_station_name"`
+	ToStationName   string `json:"to_station_name"`
+}
+
+type OrderPassenger struct {
+	PassengerName string `json:"passenger_name"`
+}
+
+// OrderTicket 订单里的一张车票
+type OrderTicket struct {
+	StationTrain     OrderStationTrain `json:"stationTrainDTO"`
+	Passenger        OrderPassenger    `json:"passengerDTO"`
+	SeatTypeName     string            `json:"seat_type_name"`
+	CoachName        string            `json:"coach_name"`
+	SeatName         string            `json:"seat_name"`
+	StartTrainDate   string            `json:"start_train_date_page"`
+	TicketPrice      string            `json:"str_ticket_price_page"`
+	TicketStatusName string            `json:"ticket_status_name"`
+}
+
+// OrderInfo 订单信息
+type OrderInfo struct {
+	SequenceNo string        `json:"sequence_no"` // 订单号
+	OrderDate  string        `json:"order_date"`  // 下单时间
+	Tickets    []OrderTicket `json:"tickets"`
+}
+
+func (o *OrderInfo) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "订单号: %s, 下单时间: %s\n", o.SequenceNo, o.OrderDate)
+	for _, ticket := range o.Tickets {
+		fmt.Fprintf(&sb, "  %s %s %s-%s %s %s车%s %s元 %s\n",
+			ticket.StartTrainDate,
+			ticket.StationTrain.TrainCode,
+			ticket.StationTrain.FromStationName,
+			ticket.StationTrain.ToStationName,
+			ticket.Passenger.PassengerName,
+			ticket.CoachName,
+			ticket.SeatName,
+			ticket.TicketPrice,
+			ticket.TicketStatusName,
+		)
+	}
+
+	return sb.String()
+}
+
+// QueryMyOrderNoComplete 查询未完成（待支付）的订单
+func QueryMyOrderNoComplete(sess *session.Session) (orders []*OrderInfo, err error) {
+	const (
+		url0    = "https://%s/otn/queryOrder/queryMyOrderNoComplete"
+		referer = "https://kyfw.12306.cn/otn/view/train_order.html"
+	)
+
+	payload := &url.Values{}
+	payload.Add("_json_att", "")
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
+		logger.Error("查询未完成订单错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("查询未完成订单失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
+
+		return nil, errors.New("query my order no complete failure")
+	}
+
+	type QueryMyOrderNoCompleteData struct {
+		OrderDBList []*OrderInfo `json:"orderDBList"`
+	}
+
+	type QueryMyOrderNoCompleteResponse struct {
+		Status   bool                       `json:"status"`
+		Messages []string                   `json:"messages"`
+		Data     QueryMyOrderNoCompleteData `json:"data"`
+	}
+
+	response := QueryMyOrderNoCompleteResponse{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		logger.Error("解析未完成订单错误", zap.ByteString("body", body), zap.Error(err))
+
+		return
+	}
+
+	if !response.Status {
+		logger.Error("查询未完成订单失败", zap.Strings("错误消息", response.Messages))
+
+		return nil, errors.New(strings.Join(response.Messages, ""))
+	}
+
+	return response.Data.OrderDBList, nil
+}
+
+// QueryMyOrder 查询最近 30 天内下单的未出行订单
+func QueryMyOrder(sess *session.Session) (orders []*OrderInfo, err error) {
+	const (
+		url0    = "https://%s/otn/queryOrder/queryMyOrder"
+		referer = "https://kyfw.12306.cn/otn/view/train_order.html"
+	)
+
+	now := time.Now()
+
+	payload := &url.Values{}
+	payload.Add("come_from_flag", "my_order")
+	payload.Add("pageIndex", "0")
+	payload.Add("pageSize", "8")
+	payload.Add("query_where", "G") // G - 未出行，H - 历史订单
+	payload.Add("queryStartDate", now.AddDate(0, 0, -30).Format("2006-01-02"))
+	payload.Add("queryEndDate", now.Format("2006-01-02"))
+	payload.Add("queryType", "1") // 1 - 按订票日期查询，2 - 按乘车日期查询
+	payload.Add("sequeue_train_name", "")
+
+	buf := bytes.NewBuffer([]byte(payload.Encode()))
+	req, _ := http.NewRequest("POST", fmt.Sprintf(url0, sess.CDN.GetCDN()), buf)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	if body, statusCode, err = httpcli.DoHttp(req, sess.Jar); err != nil {
+		logger.Error("查询未出行订单错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("查询未出行订单失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
+
+		return nil, errors.New("query my order failure")
+	}
+
+	type QueryMyOrderData struct {
+		OrderDTODataList []*OrderInfo `json:"OrderDTODataList"`
+	}
+
+	type QueryMyOrderResponse struct {
+		Status   bool             `json:"status"`
+		Messages []string         `json:"messages"`
+		Data     QueryMyOrderData `json:"data"`
+	}
+
+	response := QueryMyOrderResponse{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		logger.Error("解析未出行订单错误", zap.ByteString("body", body), zap.Error(err))
+
+		return
+	}
+
+	if !response.Status {
+		logger.Error("查询未出行订单失败", zap.Strings("错误消息", response.Messages))
+
+		return nil, errors.New(strings.Join(response.Messages, ""))
+	}
+
+	return response.Data.OrderDTODataList, nil
+}
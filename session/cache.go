@@ -0,0 +1,57 @@
+package session
+
+import (
+	"sync"
+	"time"
+)
+
+type cacheItem struct {
+	value    interface{}
+	expireAt time.Time
+}
+
+// Cache 带有效期的缓存，零值可以直接使用，写入时清理已过期的内容
+type Cache struct {
+	mu    sync.Mutex
+	items map[string]*cacheItem
+}
+
+// Get 读取未过期的缓存
+func (c *Cache) Get(key string) (value interface{}, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	item := c.items[key]
+	if item == nil || !time.Now().Before(item.expireAt) {
+		return nil, false
+	}
+
+	return item.value, true
+}
+
+// Set 写入缓存，ttl 后过期
+func (c *Cache) Set(key string, value interface{}, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if c.items == nil {
+		c.items = make(map[string]*cacheItem)
+	}
+
+	for k, item := range c.items {
+		if !now.Before(item.expireAt) {
+			delete(c.items, k)
+		}
+	}
+
+	c.items[key] = &cacheItem{value: value, expireAt: now.Add(ttl)}
+}
+
+// Len 缓存的数量，包含已过期但还没有清理的内容
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return len(c.items)
+}
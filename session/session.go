@@ -0,0 +1,191 @@
+package session
+
+import (
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/logger"
+	"net/http/cookiejar"
+	"sync"
+
+	"go.uber.org/zap"
+)
+
+// Session 一个 12306 账号的会话，保存该账号登录、查询和下单过程中需要的所有状态，
+// 多个账号使用不同的 Session 可以互不干扰，同一账号的多个任务共用一个 Session
+//
+// 登录、查询和下单的接口仍是以 Session 为参数的函数，放在各自的包中：
+// 这些接口依赖 captcha、order 等包，改为 Session 的方法需要把它们都移到 session 包，
+// 而这些包本身又依赖 session，会形成循环引用
+type Session struct {
+	Name string // 账号名
+
+	Cfg   *config.Config      // 全局配置
+	Login *config.LoginConfig // 本会话使用的登录配置
+
+	Jar      *cookiejar.Jar
+	CDN      *cdn.Pool        // 可用 CDN 列表，可以多个会话共用
+	Stations *common.Stations // 站点列表，可以多个会话共用
+
+	LeftTicketURL  string // 余票查询 URL
+	LoginIsDisable bool   // 余票查询页面的 login_isDisable
+
+	presellMu          sync.RWMutex
+	studentPresellDays int // 学生票预售提前天数
+	otherPresellDays   int // 一般车票预售提前天数
+
+	// 12306 在服务端按登录状态保存下单页面的信息，同一账号同时只能有一个普通购票流程
+	orderMu sync.Mutex
+
+	tokenMu                    sync.RWMutex
+	repeatSubmitToken          string                 // 普通购票的 globalRepeatSubmitToken
+	ticketInfoForPassengerForm map[string]interface{} // 普通购票的 ticketInfoForPassengerForm
+
+	TrainStops   Cache // 列车时刻表缓存，Key: 车次编号_始发日期
+	TicketPrices Cache // 票价缓存，Key: 车次编号_出发站序_到达站序_座席类型_日期
+
+	passengersMu sync.RWMutex
+	passengers   map[string]*common.PassengerInfo // 联系人列表，Key: UUID
+
+	closeOnce sync.Once
+	done      chan struct{} // 会话关闭时关闭，用于停止定时检查登录状态等后台任务
+}
+
+func New(cfg *config.Config, loginCfg *config.LoginConfig, pool *cdn.Pool, stations *common.Stations) (sess *Session, err error) {
+	var jar *cookiejar.Jar
+	if jar, err = cookiejar.New(nil); err != nil {
+		logger.Error("创建 Jar 错误", zap.Error(err))
+		return
+	}
+
+	sess = &Session{
+		Cfg:      cfg,
+		Login:    loginCfg,
+		Jar:      jar,
+		CDN:      pool,
+		Stations: stations,
+
+		// 暂定 15 天，后面的 loginConf 接口可以获取正确数值
+		studentPresellDays: 15,
+		otherPresellDays:   15,
+
+		passengers: make(map[string]*common.PassengerInfo),
+
+		done: make(chan struct{}),
+	}
+
+	return
+}
+
+// Close 关闭会话，停止该会话的后台任务
+func (s *Session) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+}
+
+// Done 会话关闭后返回的 channel 将被关闭
+func (s *Session) Done() <-chan struct{} {
+	return s.done
+}
+
+// SetPresellDays 设置学生票和一般车票的预售提前天数，天数小于 1 时忽略
+func (s *Session) SetPresellDays(student, other int) {
+	s.presellMu.Lock()
+	defer s.presellMu.Unlock()
+
+	if student > 0 {
+		s.studentPresellDays = student
+	}
+
+	if other > 0 {
+		s.otherPresellDays = other
+	}
+}
+
+// PresellDays 学生票或一般车票的预售提前天数
+func (s *Session) PresellDays(student bool) int {
+	s.presellMu.RLock()
+	defer s.presellMu.RUnlock()
+
+	if student {
+		return s.studentPresellDays
+	}
+
+	return s.otherPresellDays
+}
+
+// LockOrder 开始普通购票流程，同一会话的其他任务需要等待当前流程结束
+func (s *Session) LockOrder() {
+	s.orderMu.Lock()
+}
+
+// UnlockOrder 结束普通购票流程
+func (s *Session) UnlockOrder() {
+	s.orderMu.Unlock()
+}
+
+// SetOrderToken 保存下单页面的 globalRepeatSubmitToken 和 ticketInfoForPassengerForm
+func (s *Session) SetOrderToken(token string, form map[string]interface{}) {
+	s.tokenMu.Lock()
+	defer s.tokenMu.Unlock()
+
+	s.repeatSubmitToken = token
+	s.ticketInfoForPassengerForm = form
+}
+
+// RepeatSubmitToken 普通购票的 globalRepeatSubmitToken
+func (s *Session) RepeatSubmitToken() string {
+	s.tokenMu.RLock()
+	defer s.tokenMu.RUnlock()
+
+	return s.repeatSubmitToken
+}
+
+// TicketInfoForPassengerForm 普通购票的 ticketInfoForPassengerForm，每次打开下单页面都会替换，不要修改返回的内容
+func (s *Session) TicketInfoForPassengerForm() map[string]interface{} {
+	s.tokenMu.RLock()
+	defer s.tokenMu.RUnlock()
+
+	return s.ticketInfoForPassengerForm
+}
+
+// SetPassengers 替换联系人列表
+func (s *Session) SetPassengers(passengers common.PassengerInfos) {
+	m := make(map[string]*common.PassengerInfo, len(passengers))
+	for _, passenger := range passengers {
+		m[passenger.UUID] = passenger
+	}
+
+	s.passengersMu.Lock()
+	s.passengers = m
+	s.passengersMu.Unlock()
+}
+
+// PassengerCount 联系人数量，未登录时为 0
+func (s *Session) PassengerCount() int {
+	s.passengersMu.RLock()
+	defer s.passengersMu.RUnlock()
+
+	return len(s.passengers)
+}
+
+func (s *Session) GetPassenger(passengerName string) *common.PassengerInfo {
+	s.passengersMu.RLock()
+	defer s.passengersMu.RUnlock()
+
+	for _, passengerInfo := range s.passengers {
+		if passengerInfo.PassengerName == passengerName {
+			return passengerInfo
+		}
+	}
+
+	return nil
+}
+
+func (s *Session) GetPassengerByUUID(uuid string) *common.PassengerInfo {
+	s.passengersMu.RLock()
+	defer s.passengersMu.RUnlock()
+
+	return s.passengers[uuid]
+}
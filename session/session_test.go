@@ -0,0 +1,151 @@
+package session_test
+
+import (
+	"fmt"
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPassengersIsolation(t *testing.T) {
+	cfg := &config.Config{}
+	pool := cdn.NewPool()
+	stations := common.NewStations()
+
+	sess1, err := session.New(cfg, &config.LoginConfig{Username: "user1"}, pool, stations)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	sess2, err := session.New(cfg, &config.LoginConfig{Username: "user2"}, pool, stations)
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	sess1.SetPassengers(common.PassengerInfos{{PassengerName: "张三", UUID: "uuid1"}})
+	sess2.SetPassengers(common.PassengerInfos{{PassengerName: "李四", UUID: "uuid2"}})
+
+	if sess1.GetPassenger("张三") == nil || sess1.GetPassenger("李四") != nil {
+		t.Error("session 1 passengers error")
+	}
+
+	if sess2.GetPassengerByUUID("uuid2") == nil || sess2.GetPassengerByUUID("uuid1") != nil {
+		t.Error("session 2 passengers error")
+	}
+
+	if sess1.Jar == sess2.Jar {
+		t.Error("sessions share the same cookie jar")
+	}
+}
+
+func TestOrderTokenConcurrent(t *testing.T) {
+	cfg := &config.Config{}
+	sess, err := session.New(cfg, &cfg.Login, cdn.NewPool(), common.NewStations())
+	if err != nil {
+		t.Error(err.Error())
+		return
+	}
+
+	// 同一账号的多个任务同时下单时，token 的读写不能交叉
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			token := fmt.Sprintf("token%d", i)
+
+			sess.LockOrder()
+			defer sess.UnlockOrder()
+
+			sess.SetOrderToken(token, map[string]interface{}{"purpose_codes": token})
+			if sess.RepeatSubmitToken() != token || sess.TicketInfoForPassengerForm()["purpose_codes"] != token {
+				t.Errorf("token %s was overwritten", token)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConcurrentSessions(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	cfg := &config.Config{}
+	stations := common.NewStations()
+	u, _ := url.Parse("https://kyfw.12306.cn/otn/")
+
+	// 两个账号同时运行，各自的 token、Cookie 和 CDN 互不影响
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		pool := cdn.NewPool()
+		host := fmt.Sprintf("127.0.0.%d:8443", i+1)
+		pool.SetEndpoint(host, host)
+
+		sess, err := session.New(cfg, &config.LoginConfig{Username: fmt.Sprintf("user%d", i)}, pool, stations)
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+		defer sess.Close()
+
+		wg.Add(1)
+		go func(sess *session.Session, name, host string) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				token := fmt.Sprintf("%s_token%d", name, j)
+
+				sess.LockOrder()
+				sess.SetOrderToken(token, nil)
+				sess.Jar.SetCookies(u, []*http.Cookie{{Name: "tk", Value: token}})
+
+				if sess.RepeatSubmitToken() != token {
+					t.Errorf("%s: token %s was overwritten by %s", name, token, sess.RepeatSubmitToken())
+				}
+
+				if cookies := sess.Jar.Cookies(u); len(cookies) != 1 || cookies[0].Value != token {
+					t.Errorf("%s: unexpected cookies %v", name, cookies)
+				}
+				sess.UnlockOrder()
+
+				if cdn := sess.CDN.GetCDN(); cdn != host {
+					t.Errorf("%s: cdn %s, want %s", name, cdn, host)
+				}
+			}
+		}(sess, sess.Login.Username, host)
+	}
+	wg.Wait()
+}
+
+func TestCache(t *testing.T) {
+	var cache session.Cache
+
+	if _, ok := cache.Get("a"); ok {
+		t.Error("empty cache should miss")
+	}
+
+	cache.Set("a", 1, time.Hour)
+	cache.Set("b", 2, -time.Second) // 已过期
+
+	if v, ok := cache.Get("a"); !ok || v.(int) != 1 {
+		t.Errorf("get a: %v, %v", v, ok)
+	}
+
+	if _, ok := cache.Get("b"); ok {
+		t.Error("expired item should miss")
+	}
+
+	// 写入时清理已过期的内容
+	cache.Set("c", 3, time.Hour)
+	if n := cache.Len(); n != 2 {
+		t.Errorf("cache len %d, want 2", n)
+	}
+}
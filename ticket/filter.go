@@ -0,0 +1,187 @@
+package ticket
+
+import (
+	"fmt"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/session"
+	"strings"
+	"time"
+)
+
+// QueryFilter 余票查询结果的筛选条件，字段为空时不筛选
+type QueryFilter struct {
+	TrainTypes  string // 车次类型，车次首字母的组合，如 GD
+	DepartFrom  string // 出发时间范围，HH:MM
+	DepartTo    string
+	ArriveFrom  string // 到达时间范围，HH:MM
+	ArriveTo    string
+	SeatIndices []int // 这些座席中至少有一个有余票
+
+	MaxDuration       int      // 最长历时，单位: 分钟，0 为不限
+	FromTelegramCodes []string // 出发站，电报码
+	ToTelegramCodes   []string // 到达站，电报码
+}
+
+// ParseTimeWindow 解析时间范围，格式为 HH:MM-HH:MM，空字符串表示不限
+func ParseTimeWindow(window string) (from, to string, err error) {
+	if window == "" {
+		return
+	}
+
+	parts := strings.Split(window, "-")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("时间范围 %q 格式错误，应为 HH:MM-HH:MM", window)
+	}
+
+	for _, part := range parts {
+		if _, err = time.Parse("15:04", part); err != nil {
+			return "", "", fmt.Errorf("时间范围 %q 格式错误，应为 HH:MM-HH:MM", window)
+		}
+	}
+
+	return parts[0], parts[1], nil
+}
+
+// NewQueryFilter 根据命令行参数创建筛选条件
+// trainTypes 如 GD，departWindow/arriveWindow 如 08:00-12:00，seatNames 为座席名称
+func NewQueryFilter(trainTypes, departWindow, arriveWindow string, seatNames []string) (filter *QueryFilter, err error) {
+	filter = &QueryFilter{
+		TrainTypes: strings.ToUpper(trainTypes),
+	}
+
+	for _, c := range filter.TrainTypes {
+		if !strings.ContainsRune("GDCKZTLSY", c) {
+			return nil, fmt.Errorf("未知的车次类型 %q", c)
+		}
+	}
+
+	if filter.DepartFrom, filter.DepartTo, err = ParseTimeWindow(departWindow); err != nil {
+		return nil, err
+	}
+
+	if filter.ArriveFrom, filter.ArriveTo, err = ParseTimeWindow(arriveWindow); err != nil {
+		return nil, err
+	}
+
+	if len(seatNames) > 0 {
+		if _, filter.SeatIndices, err = seatNamesToSeatIndices(seatNames); err != nil {
+			return nil, fmt.Errorf("未知的座席类型 %v", seatNames)
+		}
+	}
+
+	return
+}
+
+// NewTrainFilter 根据任务的车次筛选规则创建筛选条件，站点使用 sess 的站点列表解析
+func NewTrainFilter(sess *session.Session, cfg *config.TrainFilterConfig) (filter *QueryFilter, err error) {
+	if filter, err = NewQueryFilter(cfg.TrainTypes, cfg.Depart, cfg.Arrive, nil); err != nil {
+		return
+	}
+
+	if cfg.MaxDurationMinutes < 0 {
+		return nil, fmt.Errorf("最长历时 %d 分钟不能为负数", cfg.MaxDurationMinutes)
+	}
+	filter.MaxDuration = cfg.MaxDurationMinutes
+
+	for _, station := range cfg.FromStations {
+		var stations []*common.StationInfo
+		if stations, err = sess.Stations.Resolve(station); err != nil {
+			return nil, fmt.Errorf("出发站错误: %w", err)
+		}
+
+		for _, s := range stations {
+			filter.FromTelegramCodes = append(filter.FromTelegramCodes, s.TelegramCode)
+		}
+	}
+
+	for _, station := range cfg.ToStations {
+		var stations []*common.StationInfo
+		if stations, err = sess.Stations.Resolve(station); err != nil {
+			return nil, fmt.Errorf("到达站错误: %w", err)
+		}
+
+		for _, s := range stations {
+			filter.ToTelegramCodes = append(filter.ToTelegramCodes, s.TelegramCode)
+		}
+	}
+
+	return
+}
+
+// inTimeWindow 时间是否在范围内，from 大于 to 时表示跨过零点，如 22:00-06:00
+func inTimeWindow(t, from, to string) bool {
+	if from == "" {
+		return true
+	}
+
+	if from <= to {
+		return t >= from && t <= to
+	}
+
+	return t >= from || t <= to
+}
+
+// Match 车次是否符合筛选条件
+func (f *QueryFilter) Match(info *common.LeftTicketInfo) bool {
+	if f.TrainTypes != "" {
+		trainCode := strings.ToUpper(info.TrainCode)
+		if trainCode == "" || !strings.ContainsRune(f.TrainTypes, rune(trainCode[0])) {
+			return false
+		}
+	}
+
+	if !inTimeWindow(info.StartTime, f.DepartFrom, f.DepartTo) ||
+		!inTimeWindow(info.ArriveTime, f.ArriveFrom, f.ArriveTo) {
+		return false
+	}
+
+	if f.MaxDuration > 0 && durationMinutes(info.Duration) > f.MaxDuration {
+		return false
+	}
+
+	if len(f.FromTelegramCodes) > 0 && !inStringArray(info.FromTelegramCode, f.FromTelegramCodes) {
+		return false
+	}
+
+	if len(f.ToTelegramCodes) > 0 && !inStringArray(info.ToTelegramCode, f.ToTelegramCodes) {
+		return false
+	}
+
+	if len(f.SeatIndices) > 0 {
+		for _, seatIndex := range f.SeatIndices {
+			if seatIndex < len(info.LeftTicketsCount) && info.LeftTicketsCount[seatIndex] > 0 {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// MatchTransfer 中转方案是否符合筛选条件：车次类型对两程都生效，出发时间和出发站对第一程生效，
+// 到达时间和到达站对第二程生效，最长历时对全程（包括换乘等待时间）生效
+func (f *QueryFilter) MatchTransfer(plan *TransferPlan) bool {
+	if f.MaxDuration > 0 && plan.Duration() > time.Minute*time.Duration(f.MaxDuration) {
+		return false
+	}
+
+	first, second := *f, *f
+	first.ArriveFrom, first.ArriveTo, first.ToTelegramCodes, first.MaxDuration = "", "", nil, 0
+	second.DepartFrom, second.DepartTo, second.FromTelegramCodes, second.MaxDuration = "", "", nil, 0
+
+	return first.Match(plan.First) && second.Match(plan.Second)
+}
+
+// FilterLeftTickets 返回符合筛选条件的车次
+func FilterLeftTickets(infos []*common.LeftTicketInfo, filter *QueryFilter) (matched []*common.LeftTicketInfo) {
+	for _, info := range infos {
+		if filter.Match(info) {
+			matched = append(matched, info)
+		}
+	}
+
+	return
+}
@@ -0,0 +1,157 @@
+package ticket_test
+
+import (
+	"gogo12306/common"
+	"gogo12306/logger"
+	"gogo12306/ticket"
+	"gogo12306/worker"
+	"testing"
+	"time"
+)
+
+func TestQueryFilter(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	infos := []*common.LeftTicketInfo{
+		{TrainCode: "G1", StartTime: "08:00", ArriveTime: "12:00", LeftTicketsCount: []int{0, 0, 0, 5}},
+		{TrainCode: "D2", StartTime: "13:00", ArriveTime: "18:00", LeftTicketsCount: []int{0, 0, 3, 0}},
+		{TrainCode: "K3", StartTime: "23:00", ArriveTime: "06:00", LeftTicketsCount: []int{0, 0, 0, 0, 0, 0, 0, 0, 0, 20}},
+	}
+
+	cases := []struct {
+		trainTypes, depart, arrive string
+		seats                      []string
+		want                       []string
+	}{
+		{"", "", "", nil, []string{"G1", "D2", "K3"}},
+		{"gd", "", "", nil, []string{"G1", "D2"}},
+		{"", "07:00-13:00", "", nil, []string{"G1", "D2"}},
+		{"", "22:00-06:00", "", nil, []string{"K3"}},
+		{"", "", "17:00-19:00", nil, []string{"D2"}},
+		{"", "", "", []string{"二等座", "硬座"}, []string{"G1", "K3"}},
+	}
+
+	for i, c := range cases {
+		filter, err := ticket.NewQueryFilter(c.trainTypes, c.depart, c.arrive, c.seats)
+		if err != nil {
+			t.Errorf("case %d: %s", i, err.Error())
+			continue
+		}
+
+		var got []string
+		for _, info := range ticket.FilterLeftTickets(infos, filter) {
+			got = append(got, info.TrainCode)
+		}
+
+		if len(got) != len(c.want) {
+			t.Errorf("case %d: got %v, want %v", i, got, c.want)
+			continue
+		}
+
+		for j := range got {
+			if got[j] != c.want[j] {
+				t.Errorf("case %d: got %v, want %v", i, got, c.want)
+				break
+			}
+		}
+	}
+
+	if _, err := ticket.NewQueryFilter("X", "", "", nil); err == nil {
+		t.Error("unknown train type should fail")
+	}
+
+	if _, err := ticket.NewQueryFilter("", "8-12", "", nil); err == nil {
+		t.Error("bad time window should fail")
+	}
+
+	if _, err := ticket.NewQueryFilter("", "", "", []string{"站票"}); err == nil {
+		t.Error("unknown seat should fail")
+	}
+}
+
+func TestTrainFilterRules(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	infos := []*common.LeftTicketInfo{
+		{TrainCode: "G1", FromTelegramCode: "IZQ", ToTelegramCode: "AOH", StartTime: "08:00", ArriveTime: "14:57", Duration: "06:57"},
+		{TrainCode: "D2", FromTelegramCode: "IZQ", ToTelegramCode: "AOH", StartTime: "06:39", ArriveTime: "17:43", Duration: "11:04"},
+		{TrainCode: "Z3", FromTelegramCode: "GZQ", ToTelegramCode: "SHH", StartTime: "18:00", ArriveTime: "10:30", Duration: "16:30"},
+	}
+
+	filter, err := ticket.NewQueryFilter("", "", "", nil)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+	filter.MaxDuration = 12 * 60
+	filter.FromTelegramCodes = []string{"IZQ", "GZQ"}
+
+	var got []string
+	for _, info := range ticket.FilterLeftTickets(infos, filter) {
+		got = append(got, info.TrainCode)
+	}
+
+	if len(got) != 2 || got[0] != "G1" || got[1] != "D2" {
+		t.Errorf("max duration: got %v", got)
+	}
+
+	filter.MaxDuration = 0
+	filter.ToTelegramCodes = []string{"SHH"}
+	if matched := ticket.FilterLeftTickets(infos, filter); len(matched) != 1 || matched[0].TrainCode != "Z3" {
+		t.Errorf("to stations: got %v", matched)
+	}
+
+	// 待购买车次：train_codes 或符合筛选规则，排除的车次优先
+	filter, _ = ticket.NewQueryFilter("GZ", "", "", nil)
+	task := &worker.Task{
+		TrainCodes:        []string{"D2"},
+		TrainFilter:       filter,
+		ExcludeTrainCodes: []string{"Z3"},
+	}
+
+	for _, c := range []struct {
+		info *common.LeftTicketInfo
+		want bool
+	}{
+		{infos[0], true},
+		{infos[1], true},
+		{infos[2], false},
+	} {
+		if task.IsTarget(c.info) != c.want {
+			t.Errorf("IsTarget(%s) = %v, want %v", c.info.TrainCode, !c.want, c.want)
+		}
+	}
+
+	task.TrainFilter = nil
+	if task.IsTarget(infos[0]) {
+		t.Error("G1 should not be target without train filter")
+	}
+}
+
+func TestMatchTransfer(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	// 08:00 出发，10:00 到达中转站，11:00 换乘，14:00 到达
+	plan := &ticket.TransferPlan{
+		First:  &common.LeftTicketInfo{TrainCode: "G1", FromTelegramCode: "IZQ", ToTelegramCode: "CWQ", StartTime: "08:00", ArriveTime: "10:00"},
+		Second: &common.LeftTicketInfo{TrainCode: "D2", FromTelegramCode: "CWQ", ToTelegramCode: "AOH", StartTime: "11:00", ArriveTime: "14:00"},
+		Depart: time.Date(2022, 1, 2, 8, 0, 0, 0, time.UTC),
+		Arrive: time.Date(2022, 1, 2, 14, 0, 0, 0, time.UTC),
+	}
+
+	for _, c := range []struct {
+		filter *ticket.QueryFilter
+		want   bool
+	}{
+		{&ticket.QueryFilter{DepartFrom: "07:00", DepartTo: "09:00", ArriveFrom: "13:00", ArriveTo: "15:00"}, true},
+		{&ticket.QueryFilter{DepartFrom: "10:30", DepartTo: "12:00"}, false}, // 出发时间只对第一程生效
+		{&ticket.QueryFilter{ArriveFrom: "09:00", ArriveTo: "11:00"}, false}, // 到达时间只对第二程生效
+		{&ticket.QueryFilter{FromTelegramCodes: []string{"IZQ"}, ToTelegramCodes: []string{"AOH"}}, true},
+		{&ticket.QueryFilter{TrainTypes: "G"}, false},  // 车次类型对两程都生效
+		{&ticket.QueryFilter{MaxDuration: 300}, false}, // 全程 6 小时
+		{&ticket.QueryFilter{MaxDuration: 360}, true},
+	} {
+		if got := c.filter.MatchTransfer(plan); got != c.want {
+			t.Errorf("%+v: got %v, want %v", c.filter, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package ticket
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"gogo12306/common"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// 余票信息的输出格式
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+	FormatCSV   = "csv"
+)
+
+// 余票查询结果中座席的数量，对应 LeftTicketInfo.LeftTicketsCount 的索引 0 - 11
+const leftTicketSeatCount = 12
+
+// SeatLeftTickets 一种座席的余票
+type SeatLeftTickets struct {
+	SeatName string  `json:"seat_name"`       // 座席名称
+	Text     string  `json:"text"`            // 12306 返回的原始余票，如 有、无、12、--
+	Count    int     `json:"count"`           // 余票数量，“有”记为 99
+	Price    float64 `json:"price,omitempty"` // 票价，单位: 元，查询票价后才有
+}
+
+// LeftTicketOutput 一个车次的余票信息，用于 JSON 输出
+type LeftTicketOutput struct {
+	TrainCode     string            `json:"train_code"`
+	TrainNumber   string            `json:"train_number"`
+	Start         string            `json:"start"`
+	End           string            `json:"end"`
+	From          string            `json:"from"`
+	To            string            `json:"to"`
+	StartTime     string            `json:"start_time"`
+	ArriveTime    string            `json:"arrive_time"`
+	Duration      string            `json:"duration"`
+	CanOrder      bool              `json:"can_order"`
+	CanWebBuy     bool              `json:"can_web_buy"`
+	CandidateFlag bool              `json:"candidate_flag"`
+	CanCandidate  bool              `json:"can_candidate"`
+	Selected      bool              `json:"selected"` // 是否在任务的待购买车次中
+	Seats         []SeatLeftTickets `json:"seats"`
+}
+
+// LeftTicketsOutput 一次余票查询的结果，用于 JSON 输出
+type LeftTicketsOutput struct {
+	From      string             `json:"from"`
+	To        string             `json:"to"`
+	StartDate string             `json:"start_date"`
+	Trains    []LeftTicketOutput `json:"trains"`
+}
+
+// seatTexts 各座席的原始余票，顺序与 LeftTicketsCount 一致
+func seatTexts(info *common.LeftTicketInfo) []string {
+	return []string{
+		info.ShangWuZuo, info.TeDengZuo, info.YiDengZuo, info.ErDengZuo,
+		info.GaoJiRuanWo, info.RuanWo, info.DongWo, info.YingWo,
+		info.RuanZuo, info.YingZuo, info.WuZuo, info.QiTa,
+	}
+}
+
+// NewLeftTicketOutput 转换余票信息，trainCodes 为待购买车次
+func NewLeftTicketOutput(info *common.LeftTicketInfo, trainCodes []string) (output LeftTicketOutput) {
+	output = LeftTicketOutput{
+		TrainCode:     strings.ToUpper(info.TrainCode),
+		TrainNumber:   info.TrainNumber,
+		Start:         info.Start,
+		End:           info.End,
+		From:          info.From,
+		To:            info.To,
+		StartTime:     info.StartTime,
+		ArriveTime:    info.ArriveTime,
+		Duration:      info.Duration,
+		CanOrder:      info.CanOrder,
+		CanWebBuy:     info.CanWebBuy,
+		CandidateFlag: info.CandidateFlag,
+		CanCandidate:  info.CanCandidate(),
+		Selected:      inStringArray(strings.ToUpper(info.TrainCode), trainCodes),
+	}
+
+	texts := seatTexts(info)
+	for i := 0; i < leftTicketSeatCount; i++ {
+		seat := SeatLeftTickets{
+			SeatName: common.SeatIndexToSeatName(i),
+			Text:     texts[i],
+			Price:    info.Price(i),
+		}
+		if i < len(info.LeftTicketsCount) {
+			seat.Count = info.LeftTicketsCount[i]
+		}
+
+		output.Seats = append(output.Seats, seat)
+	}
+
+	return
+}
+
+// WriteLeftTickets 按格式输出余票信息，format 为空时输出表格
+// json 格式每次输出一行 JSON，csv 格式每次输出带表头的 CSV
+func WriteLeftTickets(w io.Writer, format, from, to, startDate string, infos []*common.LeftTicketInfo, trainCodes []string) (err error) {
+	switch format {
+	case "", FormatTable:
+		FprintLeftTickets(w, from, to, startDate, infos, trainCodes)
+
+	case FormatJSON:
+		output := LeftTicketsOutput{
+			From:      from,
+			To:        to,
+			StartDate: startDate,
+			Trains:    []LeftTicketOutput{},
+		}
+		for _, info := range infos {
+			output.Trains = append(output.Trains, NewLeftTicketOutput(info, trainCodes))
+		}
+
+		err = json.NewEncoder(w).Encode(&output)
+
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+
+		header := []string{
+			"start_date", "train_code", "train_number", "start", "end", "from", "to",
+			"start_time", "arrive_time", "duration",
+			"can_order", "can_web_buy", "candidate_flag", "can_candidate", "selected",
+		}
+		for i := 0; i < leftTicketSeatCount; i++ {
+			header = append(header, common.SeatIndexToSeatName(i))
+		}
+
+		if err = cw.Write(header); err != nil {
+			return
+		}
+
+		for _, info := range infos {
+			output := NewLeftTicketOutput(info, trainCodes)
+
+			record := []string{
+				startDate, output.TrainCode, output.TrainNumber, output.Start, output.End, output.From, output.To,
+				output.StartTime, output.ArriveTime, output.Duration,
+				strconv.FormatBool(output.CanOrder),
+				strconv.FormatBool(output.CanWebBuy),
+				strconv.FormatBool(output.CandidateFlag),
+				strconv.FormatBool(output.CanCandidate),
+				strconv.FormatBool(output.Selected),
+			}
+			for _, seat := range output.Seats {
+				record = append(record, strconv.Itoa(seat.Count))
+			}
+
+			if err = cw.Write(record); err != nil {
+				return
+			}
+		}
+
+		cw.Flush()
+		err = cw.Error()
+
+	default:
+		err = fmt.Errorf("未知的输出格式 %q，可选 table、json、csv", format)
+	}
+
+	return
+}
@@ -0,0 +1,63 @@
+package ticket_test
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"gogo12306/common"
+	"gogo12306/logger"
+	"gogo12306/ticket"
+	"testing"
+)
+
+func TestWriteLeftTickets(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	infos := []*common.LeftTicketInfo{
+		{
+			TrainCode: "g1", CanOrder: true, CandidateFlag: true,
+			StartTime: "08:00", ArriveTime: "12:00",
+			ErDengZuo:        "有",
+			LeftTicketsCount: []int{0, 0, 0, 99, 0, 0, 0, 0, 0, 0, 0, 0},
+			Prices:           []float64{0, 0, 0, 553.5, 0, 0, 0, 0, 0, 0, 0, 0},
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	if err := ticket.WriteLeftTickets(buf, ticket.FormatJSON, "北京南", "上海虹桥", "2022-01-02", infos, []string{"G1"}); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	output := ticket.LeftTicketsOutput{}
+	if err := json.Unmarshal(buf.Bytes(), &output); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(output.Trains) != 1 {
+		t.Fatalf("trains: %d", len(output.Trains))
+	}
+
+	train := output.Trains[0]
+	if train.TrainCode != "G1" || !train.Selected || !train.CanCandidate || len(train.Seats) != 12 ||
+		train.Seats[3].SeatName != "二等座" || train.Seats[3].Text != "有" || train.Seats[3].Count != 99 || train.Seats[3].Price != 553.5 {
+		t.Errorf("unexpected json output: %+v", train)
+	}
+
+	buf.Reset()
+	if err := ticket.WriteLeftTickets(buf, ticket.FormatCSV, "北京南", "上海虹桥", "2022-01-02", infos, nil); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	records, err := csv.NewReader(buf).ReadAll()
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(records) != 2 || len(records[0]) != len(records[1]) || records[1][1] != "G1" || records[0][18] != "二等座" || records[1][18] != "99" {
+		t.Errorf("unexpected csv output: %v", records)
+	}
+
+	if err = ticket.WriteLeftTickets(buf, "xml", "", "", "", infos, nil); err == nil {
+		t.Error("unknown format should fail")
+	}
+}
@@ -3,24 +3,21 @@ package ticket
 import (
 	"errors"
 	"fmt"
-	"gogo12306/cdn"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
-	"gogo12306/order/common"
+	"gogo12306/session"
 	"net/http"
 	"regexp"
 
 	"go.uber.org/zap"
 )
 
-var leftTicketURL string
-
-func InitLeftTickerURL() (err error) {
+func InitLeftTickerURL(sess *session.Session) (err error) {
 	const (
 		url     = "https://%s/otn/leftTicket/init"
 		referer = "https://kyfw.12306.cn/otn/resources/login.html"
 	)
-	req, _ := http.NewRequest("GET", fmt.Sprintf(url, cdn.GetCDN()), nil)
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetCDN()), nil)
 	req.Header.Set("Referer", referer)
 	httpcli.DefaultHeaders(req)
 
@@ -66,8 +63,8 @@ func InitLeftTickerURL() (err error) {
 		return errors.New("regexp 2 match failure")
 	}
 
-	leftTicketURL = string(body1[1])
-	common.LoginIsDisable = (string(body2[1]) == "Y")
+	sess.LeftTicketURL = string(body1[1])
+	sess.LoginIsDisable = (string(body2[1]) == "Y")
 
 	return
 }
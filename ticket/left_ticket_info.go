@@ -4,9 +4,11 @@ import (
 	"errors"
 	"gogo12306/common"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"go.uber.org/zap"
 )
@@ -25,7 +27,7 @@ import (
 // 26 - 无座
 // 22 - 其他
 
-func parseLeftTicketInfo(row string) (info *common.LeftTicketInfo, err error) {
+func parseLeftTicketInfo(sess *session.Session, row string) (info *common.LeftTicketInfo, err error) {
 	parts := strings.Split(row, "|")
 	if len(parts) < 40 {
 		return nil, errors.New("parts len errors")
@@ -43,34 +45,44 @@ func parseLeftTicketInfo(row string) (info *common.LeftTicketInfo, err error) {
 	info.CanWebBuy = (parts[11] == "Y" || parts[11] == "1")
 	info.CandidateFlag = (parts[37] == "1" || parts[37] == "Y")
 
-	start := StationTelegramCodeToStationInfo(parts[4])
+	start := sess.Stations.StationTelegramCodeToStationInfo(parts[4])
 	if start == nil {
 		logger.Debug("始发站名未知", zap.String("stationTelegram", parts[4]))
 	} else {
 		info.Start = start.StationName
 	}
 
-	end := StationTelegramCodeToStationInfo(parts[5])
+	end := sess.Stations.StationTelegramCodeToStationInfo(parts[5])
 	if end == nil {
 		logger.Debug("终点站名未知", zap.String("stationTelegram", parts[5]))
 	} else {
 		info.End = end.StationName
 	}
 
-	from := StationTelegramCodeToStationInfo(parts[6])
+	from := sess.Stations.StationTelegramCodeToStationInfo(parts[6])
 	if from == nil {
 		logger.Debug("出发站名未知", zap.String("stationTelegram", parts[6]))
 	} else {
 		info.From = from.StationName
 	}
 
-	to := StationTelegramCodeToStationInfo(parts[7])
+	to := sess.Stations.StationTelegramCodeToStationInfo(parts[7])
 	if to == nil {
 		logger.Debug("到达站名未知", zap.String("stationTelegram", parts[7]))
 	} else {
 		info.To = to.StationName
 	}
 
+	info.FromTelegramCode = parts[6]
+	info.ToTelegramCode = parts[7]
+	info.StartTelegramCode = parts[4]
+	info.EndTelegramCode = parts[5]
+	if t, e := time.Parse("20060102", parts[13]); e == nil {
+		info.StartTrainDate = t.Format("2006-01-02")
+	}
+	info.FromStationNo = parts[16]
+	info.ToStationNo = parts[17]
+	info.SeatTypes = parts[35]
 	info.StartTime = parts[8]
 	info.ArriveTime = parts[9]
 	info.Duration = parts[10]
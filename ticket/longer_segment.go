@@ -0,0 +1,254 @@
+package ticket
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gogo12306/blacklist"
+	"gogo12306/common"
+	"gogo12306/logger"
+	"gogo12306/order"
+	"gogo12306/session"
+	"gogo12306/worker"
+
+	"go.uber.org/zap"
+)
+
+// LongerSegment 买长乘短：同一车次覆盖出发站到到达站的更长区间
+type LongerSegment struct {
+	Info      *common.LeftTicketInfo // 更长区间的余票信息
+	StartDate string                 // 更长区间的出发日期，从更早的停靠站出发时可能早于原区间的出发日期
+}
+
+// 买长乘短最多查询的区间数量，避免停靠站很多时查询次数过多
+const maxLongerSegments = 6
+
+// DefaultLongerSegmentInterval 同一车次两次查询更长区间的默认最短间隔
+const DefaultLongerSegmentInterval = time.Minute
+
+// leftTicketsCache 一轮查询中已查询过的余票，Key: 出发站_到达站_出发日期
+type leftTicketsCache map[string][]*common.LeftTicketInfo
+
+// query 查询余票，本轮已查询过的区间直接使用之前的结果
+func (c leftTicketsCache) query(sess *session.Session, from, to, startDate string, student bool) (infos []*common.LeftTicketInfo, err error) {
+	key := from + "_" + to + "_" + startDate
+	if infos, ok := c[key]; ok {
+		return infos, nil
+	}
+
+	if infos, err = QueryLeftTickets(sess, from, to, startDate, student); err != nil {
+		return
+	}
+
+	c[key] = infos
+	return
+}
+
+// longerSegmentCandidate 待查询的更长区间
+type longerSegmentCandidate struct {
+	From, To  string // 电报码
+	StartDate string // 从 From 出发的日期
+	extra     int    // 比原区间多出的停靠站数
+}
+
+// longerSegmentCandidates 车次覆盖出发站到到达站的更长区间
+// 能获取到时刻表时，使用出发站及之前的停靠站到到达站及之后的停靠站的所有组合，多出的停靠站少的在前；
+// 否则使用始发站到到达站、出发站到终到站、始发站到终到站
+func longerSegmentCandidates(sess *session.Session, info *common.LeftTicketInfo, startDate string) (candidates []*longerSegmentCandidate) {
+	trainDate := info.StartTrainDate
+	if trainDate == "" {
+		trainDate = startDate
+	}
+
+	stops, err := QueryTrainStops(sess, info.TrainNumber, info.StartTelegramCode, info.EndTelegramCode, trainDate)
+	if err != nil {
+		logger.Warn("获取列车时刻表失败，只查询始发站和终到站的区间", zap.String("车次", info.TrainCode), zap.Error(err))
+	}
+
+	fromIndex, toIndex := stops.Index(info.FromTelegramCode), stops.Index(info.ToTelegramCode)
+	if fromIndex < 0 || toIndex <= fromIndex {
+		return originTerminalCandidates(info, startDate)
+	}
+
+	date, _ := time.Parse("2006-01-02", startDate)
+	for i := fromIndex; i >= 0; i-- {
+		if stops[i].TelegramCode == "" {
+			continue
+		}
+
+		// 从更早的停靠站出发时，出发日期可能是前一天
+		boardDate := date.AddDate(0, 0, stops[i].DepartDay-stops[fromIndex].DepartDay).Format("2006-01-02")
+
+		for j := toIndex; j < len(stops); j++ {
+			if stops[j].TelegramCode == "" || (i == fromIndex && j == toIndex) {
+				continue
+			}
+
+			candidates = append(candidates, &longerSegmentCandidate{
+				From:      stops[i].TelegramCode,
+				To:        stops[j].TelegramCode,
+				StartDate: boardDate,
+				extra:     fromIndex - i + j - toIndex,
+			})
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].extra < candidates[j].extra
+	})
+
+	if len(candidates) > maxLongerSegments {
+		candidates = candidates[:maxLongerSegments]
+	}
+
+	return
+}
+
+// originTerminalCandidates 始发站到到达站、出发站到终到站、始发站到终到站
+func originTerminalCandidates(info *common.LeftTicketInfo, startDate string) (candidates []*longerSegmentCandidate) {
+	// 从始发站出发时，出发日期为列车的始发日期
+	originDate := startDate
+	if info.StartTrainDate != "" {
+		originDate = info.StartTrainDate
+	}
+
+	if info.StartTelegramCode != "" && info.StartTelegramCode != info.FromTelegramCode {
+		candidates = append(candidates, &longerSegmentCandidate{From: info.StartTelegramCode, To: info.ToTelegramCode, StartDate: originDate})
+	}
+
+	if info.EndTelegramCode != "" && info.EndTelegramCode != info.ToTelegramCode {
+		candidates = append(candidates, &longerSegmentCandidate{From: info.FromTelegramCode, To: info.EndTelegramCode, StartDate: startDate})
+	}
+
+	if len(candidates) == 2 {
+		candidates = append(candidates, &longerSegmentCandidate{From: info.StartTelegramCode, To: info.EndTelegramCode, StartDate: originDate})
+	}
+
+	return
+}
+
+// QueryLongerSegments 查询车次的更长区间的余票，结果按乘车时间从短到长排序（区间越短票价通常越低）
+func QueryLongerSegments(sess *session.Session, info *common.LeftTicketInfo, startDate string, student bool) (segments []*LongerSegment, err error) {
+	return queryLongerSegments(sess, info, startDate, student, make(leftTicketsCache))
+}
+
+func queryLongerSegments(sess *session.Session, info *common.LeftTicketInfo, startDate string, student bool,
+	queried leftTicketsCache) (segments []*LongerSegment, err error) {
+	for _, candidate := range longerSegmentCandidates(sess, info, startDate) {
+		var infos []*common.LeftTicketInfo
+		if infos, err = queried.query(sess, candidate.From, candidate.To, candidate.StartDate, student); err != nil {
+			return
+		}
+
+		for _, i := range infos {
+			if i.TrainCode == info.TrainCode && i.FromTelegramCode == candidate.From && i.ToTelegramCode == candidate.To {
+				segments = append(segments, &LongerSegment{Info: i, StartDate: candidate.StartDate})
+				break
+			}
+		}
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		return durationMinutes(segments[i].Info.Duration) < durationMinutes(segments[j].Info.Duration)
+	})
+
+	return
+}
+
+// durationMinutes 历时（HH:MM）的分钟数，格式错误时返回一个很大的数
+func durationMinutes(duration string) int {
+	var hours, minutes int
+	if _, err := fmt.Sscanf(duration, "%d:%d", &hours, &minutes); err != nil {
+		return 1 << 30
+	}
+
+	return hours*60 + minutes
+}
+
+// cheapestLongerSegments 按座席的票价从低到高排序，查询不到票价的区间排在最后，保持按历时排序的顺序
+func cheapestLongerSegments(segments []*LongerSegment, seatIndex int) []*LongerSegment {
+	sorted := append([]*LongerSegment{}, segments...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, pj := sorted[i].Info.Price(seatIndex), sorted[j].Info.Price(seatIndex)
+		if pi <= 0 || pj <= 0 {
+			return pi > 0 && pj <= 0
+		}
+
+		return pi < pj
+	})
+
+	return sorted
+}
+
+// hasEnoughTickets 任务的座席中是否有余票足够所有乘客的座席
+func hasEnoughTickets(task *worker.Task, info *common.LeftTicketInfo) bool {
+	for _, seatIndex := range task.SeatIndices {
+		if info.LeftTicketsCount[seatIndex] >= len(task.Passengers) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// longerSegmentKey 更长区间在小黑屋中的车次，与原区间区分开
+func longerSegmentKey(info *common.LeftTicketInfo) string {
+	return info.TrainCode + "-" + info.FromTelegramCode + "-" + info.ToTelegramCode
+}
+
+// orderLongerSegment 车次在原区间下单失败后，尝试购买该车次的更长区间，queried 为本轮已查询过的余票
+// 按座席顺序选择有足够余票的最便宜的区间，查询不到票价时选择历时最短的区间，下单成功时返回 true
+func orderLongerSegment(sess *session.Session, task *worker.Task, info *common.LeftTicketInfo, startDate string, queried leftTicketsCache) bool {
+	segments, err := queryLongerSegments(sess, info, startDate, task.Student, queried)
+	if err != nil {
+		logger.Error("查询买长乘短区间余票错误", zap.String("车次", info.TrainCode), zap.Error(err))
+
+		return false
+	}
+
+	// 只查询有足够余票的区间的票价
+	for _, segment := range segments {
+		if segment.Info.CanOrder && hasEnoughTickets(task, segment.Info) {
+			AttachTicketPrices(sess, []*common.LeftTicketInfo{segment.Info}, segment.StartDate)
+		}
+	}
+
+	for _, seatIndex := range task.SeatIndices {
+		for _, segment := range cheapestLongerSegments(segments, seatIndex) {
+			key := longerSegmentKey(segment.Info)
+			if !segment.Info.CanOrder ||
+				segment.Info.LeftTicketsCount[seatIndex] < len(task.Passengers) ||
+				blacklist.IsInBlackList(task.TaskID, key, seatIndex) ||
+				!withinMaxPrice(sess, task, segment.Info, segment.StartDate, seatIndex) {
+				continue
+			}
+
+			logger.Info("原区间没有余票，尝试买长乘短...",
+				zap.String("车次", segment.Info.TrainCode),
+				zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+				zap.String("乘车区间", info.From+" - "+info.To),
+				zap.String("购买区间", segment.Info.From+" - "+segment.Info.To),
+				zap.String("出发日期", segment.StartDate),
+				zap.Int("余票", segment.Info.LeftTicketsCount[seatIndex]),
+				zap.Float64("票价", segment.Info.Price(seatIndex)),
+			)
+
+			if err = order.DoOrder(sess, task, segment.Info, segment.StartDate, segment.Info.TrainCode, seatIndex,
+				passengerTickets(task, len(task.Passengers), seatIndex)); err != nil {
+				logger.Warn("买长乘短下单失败，将此区间加入小黑屋",
+					zap.String("车次", segment.Info.TrainCode),
+					zap.String("购买区间", segment.Info.From+" - "+segment.Info.To),
+					zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+				)
+
+				blacklist.AddToBlackList(task.TaskID, key, seatIndex, task.BlackTime)
+				continue
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
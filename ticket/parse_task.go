@@ -2,8 +2,11 @@ package ticket
 
 import (
 	"errors"
+	"fmt"
+	"gogo12306/clock"
+	"gogo12306/common"
 	"gogo12306/config"
-	"gogo12306/login"
+	"gogo12306/session"
 	"gogo12306/worker"
 	"strings"
 	"time"
@@ -90,33 +93,134 @@ func seatNamesToSeatIndices(seatNames []string) (types, indices []int, err error
 	return
 }
 
-func ParseTask(taskCfg *config.TaskConfig) (task *worker.Task, err error) {
+// SaleTime 出发日期的开售时间（北京时间）：出发日期减去（预售天数 - 1）天，再加上站点的开售时间
+func SaleTime(startDate string, stationSaleTime time.Duration, presellDays int) (saleTime time.Time, err error) {
+	if saleTime, err = time.ParseInLocation("2006-01-02", startDate, clock.Shanghai); err != nil {
+		return
+	}
+
+	return saleTime.AddDate(0, 0, -(presellDays - 1)).Add(stationSaleTime), nil
+}
+
+// saleTimes 按会话当前的预售天数计算任务所有出发日期的开售时间
+func saleTimes(sess *session.Session, task *worker.Task) (times []time.Time, err error) {
+	// 有多个出发站时使用最早的开售时间
+	stationSaleTime := earliestSaleTime(task.FromStations)
+	presellDays := sess.PresellDays(task.Student)
+
+	for _, date := range task.StartDates {
+		var saleTime time.Time
+		if saleTime, err = SaleTime(date, stationSaleTime, presellDays); err != nil {
+			return nil, err
+		}
+
+		times = append(times, saleTime)
+	}
+
+	return
+}
+
+// earliestSaleTime 多个站点中最早的开售时间
+func earliestSaleTime(stations []*common.StationInfo) (saleTime time.Duration) {
+	for i, station := range stations {
+		if i == 0 || station.SaleTime < saleTime {
+			saleTime = station.SaleTime
+		}
+	}
+
+	return
+}
+
+// taskTicketType 解析任务的车票类型，只能是成人票或学生票
+func taskTicketType(name string) (ticketType int, err error) {
+	if ticketType, err = common.ParseTicketType(name); err != nil {
+		return
+	}
+
+	if ticketType != common.TicketTypeAdult && ticketType != common.TicketTypeStudent {
+		return 0, fmt.Errorf("任务的车票类型只能是 adult 或 student，不能是 %q", name)
+	}
+
+	return
+}
+
+// ticketTypeAt 配置中第 i 个乘客的车票类型，没有配置或留空时为 0
+func ticketTypeAt(names []string, i int) (ticketType int, err error) {
+	if i >= len(names) || strings.TrimSpace(names[i]) == "" {
+		return 0, nil
+	}
+
+	return common.ParseTicketType(names[i])
+}
+
+// passengerTicketType 检查并返回乘客实际购买的车票类型，ticketType 为配置中该乘客的车票类型
+// ticketType 为 0 时，学生票任务购买学生票，否则按联系人的乘客类型购买（学生购买成人票）
+// 儿童票可以用任意乘客的证件购买，学生票和残军票只能给对应类型的乘客购买
+func passengerTicketType(student bool, ticketType int, passenger *common.PassengerInfo) (int, error) {
+	if ticketType == 0 {
+		switch {
+		case student:
+			ticketType = common.TicketTypeStudent
+
+		case passenger.PassengerType == common.TicketTypeChild, passenger.PassengerType == common.TicketTypeDisabled:
+			ticketType = passenger.PassengerType
+
+		default:
+			ticketType = common.TicketTypeAdult
+		}
+	}
+
+	switch ticketType {
+	case common.TicketTypeStudent:
+		if !student {
+			return 0, fmt.Errorf("乘车人 %q 购买学生票需要将任务的 ticket_type 设为 student", passenger.PassengerName)
+		}
+
+		if passenger.PassengerType != common.TicketTypeStudent {
+			return 0, fmt.Errorf("乘车人 %q 不是学生，不能购买学生票", passenger.PassengerName)
+		}
+
+	case common.TicketTypeDisabled:
+		if passenger.PassengerType != common.TicketTypeDisabled {
+			return 0, fmt.Errorf("乘车人 %q 不是残疾军人，不能购买残军票", passenger.PassengerName)
+		}
+	}
+
+	return ticketType, nil
+}
+
+func ParseTask(sess *session.Session, taskCfg *config.TaskConfig) (task *worker.Task, err error) {
 	task = &worker.Task{
 		TaskID:         time.Now().UnixNano(),
 		QueryOnly:      taskCfg.QueryOnly,
+		QueryFormat:    taskCfg.QueryFormat,
 		Done:           make(chan struct{}, 1),
 		OrderType:      taskCfg.OrderType,
 		BlackTime:      taskCfg.BlackTime,
 		AllowCandidate: taskCfg.AllowCandidate,
 		NextQueryTime:  time.Now(),
-		CB:             QueryLeftTicket,
 	}
+	task.CB = func(t *worker.Task) error {
+		return QueryLeftTicket(sess, t)
+	}
+
+	// 车票类型，任务只能购买成人票或学生票
+	var ticketType int
+	if ticketType, err = taskTicketType(taskCfg.TicketType); err != nil {
+		return nil, err
+	}
+	task.Student = ticketType == common.TicketTypeStudent
 
-	from := StationNameToStationInfo(taskCfg.From)
-	if from == nil {
+	// 站点可以是站点名、电报码、拼音、拼音首字母、拼音码、城市或多个站点
+	if task.FromStations, err = sess.Stations.Resolve(taskCfg.From); err != nil {
 		return nil, errors.New("from error")
-	} else {
-		task.From = taskCfg.From
-		task.FromTelegramCode = from.TelegramCode
 	}
+	task.From = common.StationNames(task.FromStations)
 
-	to := StationNameToStationInfo(taskCfg.To)
-	if to == nil {
+	if task.ToStations, err = sess.Stations.Resolve(taskCfg.To); err != nil {
 		return nil, errors.New("to error")
-	} else {
-		task.To = taskCfg.To
-		task.ToTelegramCode = to.TelegramCode
 	}
+	task.To = common.StationNames(task.ToStations)
 
 	if len(taskCfg.StartDates) <= 0 {
 		return nil, errors.New("start_dates error")
@@ -146,18 +250,16 @@ func ParseTask(taskCfg *config.TaskConfig) (task *worker.Task, err error) {
 		}
 	}
 
-	// 计算开售时间
-	for _, date := range taskCfg.StartDates {
-		var saleTime time.Time
-		if saleTime, err = time.Parse("2006-01-02", date); err != nil {
-			return nil, errors.New("start_dates error")
-		}
-
-		// 需要减去（预售天数 - 1）
-		saleTime = saleTime.Add(from.SaleTime - time.Hour*24*time.Duration(config.Cfg.OtherPresellDays-1))
+	// 计算开售时间，登录后获取到准确的预售天数时重新计算
+	task.StartDates = append(task.StartDates, taskCfg.StartDates...)
+	if task.SaleTimes, err = saleTimes(sess, task); err != nil {
+		return nil, errors.New("start_dates error")
+	}
 
-		task.StartDates = append(task.StartDates, date)
-		task.SaleTimes = append(task.SaleTimes, saleTime)
+	task.UpdateSaleTimes = func(t *worker.Task) {
+		if times, e := saleTimes(sess, t); e == nil {
+			t.SaleTimes = times
+		}
 	}
 
 	// 开售时间排序，从最快开售到最迟开售
@@ -170,6 +272,88 @@ func ParseTask(taskCfg *config.TaskConfig) (task *worker.Task, err error) {
 		task.TrainCodes = append(task.TrainCodes, strings.TrimSpace(strings.ToUpper(trainCode)))
 	}
 
+	// 车次筛选规则
+	if taskCfg.TrainFilter != nil {
+		var filter *QueryFilter
+		if filter, err = NewTrainFilter(sess, taskCfg.TrainFilter); err != nil {
+			return nil, errors.New("train_filter error")
+		}
+		task.TrainFilter = filter
+	}
+
+	for _, trainCode := range taskCfg.ExcludeTrainCodes {
+		task.ExcludeTrainCodes = append(task.ExcludeTrainCodes, strings.TrimSpace(strings.ToUpper(trainCode)))
+	}
+
+	// 往返票的返程，出发站和到达站与去程相反
+	task.TourFlag = worker.TourFlagSingle
+	if len(taskCfg.BackStartDates) > 0 {
+		task.TourFlag = worker.TourFlagGo
+		task.Back = &worker.BackTrip{
+			From:         task.To,
+			To:           task.From,
+			FromStations: task.ToStations,
+			ToStations:   task.FromStations,
+			StartDates:   append([]string{}, taskCfg.BackStartDates...),
+		}
+
+		for _, trainCode := range taskCfg.BackTrainCodes {
+			task.Back.TrainCodes = append(task.Back.TrainCodes, strings.TrimSpace(strings.ToUpper(trainCode)))
+		}
+
+		for _, date := range task.Back.StartDates {
+			if _, err = SaleTime(date, 0, 1); err != nil {
+				return nil, errors.New("back_start_dates error")
+			}
+		}
+	}
+
+	// 中转换乘
+	if taskCfg.Transfer {
+		task.Transfer = &worker.Transfer{
+			MaxHubs:     DefaultTransferMaxHubs,
+			MinWait:     DefaultTransferMinWait,
+			MaxWait:     DefaultTransferMaxWait,
+			AfterCycles: DefaultTransferAfterCycles,
+			Interval:    DefaultTransferInterval,
+		}
+
+		if taskCfg.TransferMaxHubs > 0 {
+			task.Transfer.MaxHubs = taskCfg.TransferMaxHubs
+		}
+
+		if taskCfg.TransferAfter > 0 {
+			task.Transfer.AfterCycles = taskCfg.TransferAfter
+		}
+
+		if taskCfg.TransferInterval > 0 {
+			task.Transfer.Interval = time.Second * time.Duration(taskCfg.TransferInterval)
+		}
+
+		if taskCfg.TransferMinMinutes > 0 {
+			task.Transfer.MinWait = time.Minute * time.Duration(taskCfg.TransferMinMinutes)
+		}
+
+		if taskCfg.TransferMaxMinutes > 0 {
+			task.Transfer.MaxWait = time.Minute * time.Duration(taskCfg.TransferMaxMinutes)
+		}
+
+		for _, hub := range taskCfg.TransferHubs {
+			var stations []*common.StationInfo
+			if stations, err = sess.Stations.Resolve(hub); err != nil {
+				return nil, errors.New("transfer_hubs error")
+			}
+
+			task.Transfer.Hubs = append(task.Transfer.Hubs, stations)
+		}
+	}
+
+	task.LongerSegment = taskCfg.LongerSegment
+	task.LongerSegmentInterval = DefaultLongerSegmentInterval
+	if taskCfg.LongerSegmentInterval > 0 {
+		task.LongerSegmentInterval = time.Second * time.Duration(taskCfg.LongerSegmentInterval)
+	}
+
 	// 座位
 	task.Seats = append(task.Seats, taskCfg.Seats...)
 	if task.SeatTypes, task.SeatIndices, err = seatNamesToSeatIndices(taskCfg.Seats); err != nil {
@@ -179,12 +363,27 @@ func ParseTask(taskCfg *config.TaskConfig) (task *worker.Task, err error) {
 	// 是否接受提交无座
 	task.AllowNoSeat = taskCfg.AllowNoSeat
 
+	// 最高票价
+	task.MaxPrice = taskCfg.MaxPrice
+	for seatName, price := range taskCfg.MaxPrices {
+		var indices []int
+		if _, indices, err = seatNamesToSeatIndices([]string{seatName}); err != nil || seatName == "全部" {
+			return nil, errors.New("max_prices error")
+		}
+
+		if task.SeatMaxPrices == nil {
+			task.SeatMaxPrices = make(map[int]float64)
+		}
+		task.SeatMaxPrices[indices[0]] = price
+	}
+	task.MaxUpgradePrice = taskCfg.MaxUpgradePrice
+
 	// 乘客
 	if !taskCfg.QueryOnly { // 只查询的任务将忽略乘客信息
 		if len(taskCfg.Passengers) > 0 { // 使用乘客姓名做索引
 			for _, passengerName := range taskCfg.Passengers {
 				passengerName = strings.TrimSpace(passengerName)
-				passenger := login.GetPassenger(passengerName)
+				passenger := sess.GetPassenger(passengerName)
 				if passenger == nil {
 					return nil, errors.New("passenger name not in passenger list")
 				}
@@ -194,7 +393,7 @@ func ParseTask(taskCfg *config.TaskConfig) (task *worker.Task, err error) {
 		} else if len(taskCfg.UUIDs) > 0 { // 使用 UUID 做索引
 			for _, uuid := range taskCfg.UUIDs {
 				uuid = strings.TrimSpace(uuid)
-				passenger := login.GetPassengerByUUID(uuid)
+				passenger := sess.GetPassengerByUUID(uuid)
 				if passenger == nil {
 					return nil, errors.New("uuid not in passenger list")
 				}
@@ -202,6 +401,20 @@ func ParseTask(taskCfg *config.TaskConfig) (task *worker.Task, err error) {
 				task.Passengers = append(task.Passengers, passenger)
 			}
 		}
+
+		// 每个乘客的车票类型
+		for i, passenger := range task.Passengers {
+			var ticketType int
+			if ticketType, err = ticketTypeAt(taskCfg.TicketTypes, i); err != nil {
+				return nil, err
+			}
+
+			if ticketType, err = passengerTicketType(task.Student, ticketType, passenger); err != nil {
+				return nil, err
+			}
+
+			task.TicketTypes = append(task.TicketTypes, ticketType)
+		}
 	}
 
 	// 是否接受提交部分乘客
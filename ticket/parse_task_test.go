@@ -0,0 +1,160 @@
+package ticket_test
+
+import (
+	"gogo12306/cdn"
+	"gogo12306/clock"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"gogo12306/ticket"
+	"testing"
+	"time"
+)
+
+func TestSaleTime(t *testing.T) {
+	// 15 天预售，2022-01-15 出发的车票在 2022-01-01 开售
+	saleTime, err := ticket.SaleTime("2022-01-15", time.Hour*12+time.Minute*30, 15)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := time.Date(2022, 1, 1, 12, 30, 0, 0, clock.Shanghai)
+	if !saleTime.Equal(want) {
+		t.Errorf("got %s, want %s", saleTime, want)
+	}
+
+	// 北京时间 12:30 即 UTC 04:30，与本地时区无关
+	if utc := saleTime.UTC(); utc.Hour() != 4 || utc.Minute() != 30 {
+		t.Errorf("utc: %s", utc)
+	}
+
+	if _, err = ticket.SaleTime("2022/01/15", 0, 15); err == nil {
+		t.Error("bad date should fail")
+	}
+}
+
+func newTestSession(t *testing.T) *session.Session {
+	stations := common.NewStations()
+	stations.Add(&common.StationInfo{ID: 1, TelegramCode: "IZQ", StationName: "广州南", SaleTime: time.Hour * 12})
+	stations.Add(&common.StationInfo{ID: 2, TelegramCode: "AOH", StationName: "上海虹桥", SaleTime: time.Hour * 13})
+
+	sess, err := session.New(&config.Config{}, &config.LoginConfig{}, cdn.NewPool(), stations)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	sess.SetPassengers(common.PassengerInfos{
+		{PassengerName: "张三", PassengerType: common.TicketTypeAdult, UUID: "zhangsan"},
+		{PassengerName: "李四", PassengerType: common.TicketTypeStudent, UUID: "lisi"},
+	})
+
+	return sess
+}
+
+func TestParseTaskStudent(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	sess := newTestSession(t)
+	defer sess.Close()
+
+	// 学生票预售 30 天，一般车票预售 15 天
+	sess.SetPresellDays(30, 15)
+
+	taskCfg := &config.TaskConfig{
+		TicketType:     "student",
+		From:           "广州南",
+		To:             "上海虹桥",
+		StartDates:     []string{"2022-01-30"},
+		Seats:          []string{"二等座"},
+		SeatDetailType: []string{"0", "0", "0"},
+		Passengers:     []string{"李四"},
+		ChooseSeats:    []string{""},
+	}
+
+	task, err := ticket.ParseTask(sess, taskCfg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if !task.Student {
+		t.Error("task should be student")
+	}
+
+	want := time.Date(2022, 1, 1, 12, 0, 0, 0, clock.Shanghai)
+	if !task.SaleTimes[0].Equal(want) {
+		t.Errorf("sale time: got %s, want %s", task.SaleTimes[0], want)
+	}
+
+	// 成人不能购买学生票
+	taskCfg.Passengers = []string{"张三"}
+	if _, err = ticket.ParseTask(sess, taskCfg); err == nil {
+		t.Error("adult passenger should fail")
+	}
+
+	problems := ticket.ValidateTask(sess, "tasks[0]", taskCfg)
+	if len(problems) != 1 || problems[0].Path != "tasks[0].passengers[0]" {
+		t.Errorf("unexpected problems: %v", problems)
+	}
+
+	// 任务不能只买儿童票
+	taskCfg.TicketType = "child"
+	if _, err = ticket.ParseTask(sess, taskCfg); err == nil {
+		t.Error("child task should fail")
+	}
+}
+
+func TestParseTaskTicketTypes(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	sess := newTestSession(t)
+	defer sess.Close()
+
+	taskCfg := &config.TaskConfig{
+		From:           "广州南",
+		To:             "上海虹桥",
+		StartDates:     []string{"2022-01-30"},
+		Seats:          []string{"二等座"},
+		SeatDetailType: []string{"0", "0", "0"},
+		Passengers:     []string{"张三", "张三", "李四"},
+		TicketTypes:    []string{"", "child"},
+		ChooseSeats:    []string{"", "", ""},
+	}
+
+	// 用张三的证件购买儿童票，李四是学生但任务不是学生票，购买成人票
+	task, err := ticket.ParseTask(sess, taskCfg)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	want := []int{common.TicketTypeAdult, common.TicketTypeChild, common.TicketTypeAdult}
+	if len(task.TicketTypes) != len(want) {
+		t.Fatalf("got %v, want %v", task.TicketTypes, want)
+	}
+
+	for i := range want {
+		if task.TicketTypes[i] != want[i] {
+			t.Errorf("got %v, want %v", task.TicketTypes, want)
+			break
+		}
+	}
+
+	for _, c := range []struct {
+		ticketTypes []string
+		ok          bool
+	}{
+		{[]string{"成人票", "儿童票", "adult"}, true},
+		{[]string{"", "", "student"}, false},           // 学生票需要学生票任务
+		{[]string{"disabled-military", "", ""}, false}, // 张三不是残疾军人
+		{[]string{"senior", "", ""}, false},
+	} {
+		taskCfg.TicketTypes = c.ticketTypes
+		if _, err = ticket.ParseTask(sess, taskCfg); (err == nil) != c.ok {
+			t.Errorf("%v: got err %v, want ok %v", c.ticketTypes, err, c.ok)
+		}
+
+		if problems := ticket.ValidateTask(sess, "tasks[0]", taskCfg); (len(problems) == 0) != c.ok {
+			t.Errorf("%v: got problems %v, want ok %v", c.ticketTypes, problems, c.ok)
+		}
+	}
+}
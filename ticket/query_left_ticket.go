@@ -4,19 +4,21 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"net/http/cookiejar"
+	"os"
 	"strings"
 	"time"
 	"unicode"
 	"unicode/utf8"
 
 	"gogo12306/blacklist"
-	"gogo12306/cdn"
+	"gogo12306/clock"
 	"gogo12306/common"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
 	"gogo12306/order"
+	"gogo12306/session"
 	"gogo12306/worker"
 
 	"go.uber.org/zap"
@@ -42,16 +44,232 @@ func countHan(s string) (count int) {
 	return
 }
 
-func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
+// QueryLeftTickets 查询指定日期和区间的余票信息，不需要登录，student 为是否查询学生票
+func QueryLeftTickets(sess *session.Session, fromTelegramCode, toTelegramCode, startDate string, student bool) (infos []*common.LeftTicketInfo, err error) {
+	const (
+		url     = "https://%s/otn/%s?leftTicketDTO.train_date=%s&leftTicketDTO.from_station=%s&leftTicketDTO.to_station=%s&purpose_codes=%s"
+		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
+	)
+
+	// 查询余票页面点选 “学生票” 时为 0X00
+	purposeCodes := "ADULT"
+	if student {
+		purposeCodes = "0X00"
+	}
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetCDN(), sess.LeftTicketURL, startDate, fromTelegramCode, toTelegramCode, purposeCodes), nil)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
+	if err != nil {
+		logger.Error("获取余票查询 URL 错误", zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("获取余票查询 URL 失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
+
+		return nil, errors.New("get left ticket url failure")
+	}
+
+	type LeftTicketData struct {
+		Result []string `json:"result"`
+	}
+
+	type LeftTicketResult struct {
+		Data LeftTicketData `json:"data"`
+	}
+	result := LeftTicketResult{}
+	if err = json.Unmarshal(body, &result); err != nil {
+		logger.Error("解析余票信息错误", zap.ByteString("res", body), zap.Error(err))
+
+		return
+	}
+
+	for _, row := range result.Data.Result {
+		var leftTicketInfo *common.LeftTicketInfo
+		if leftTicketInfo, err = parseLeftTicketInfo(sess, row); err != nil || leftTicketInfo == nil {
+			logger.Error("解析余票行信息错误", zap.String("行信息", row), zap.Error(err))
+
+			continue
+		}
+
+		infos = append(infos, leftTicketInfo)
+	}
+
+	return infos, nil
+}
+
+// cityKey 站点所在城市，没有城市信息时使用站点本身，用于合并同城的查询
+func cityKey(station *common.StationInfo) string {
+	if station.CityName != "" {
+		return station.CityName
+	}
+
+	return station.TelegramCode
+}
+
+// QueryStationsLeftTickets 查询多个出发站到多个到达站的余票信息，只返回从这些出发站到这些到达站的车次
+// 余票查询会返回同城所有车站的车次，所以同城的站点组合只查询一次
+func QueryStationsLeftTickets(sess *session.Session, fromStations, toStations []*common.StationInfo, startDate string, student bool) (infos []*common.LeftTicketInfo, err error) {
+	fromCodes := make(map[string]bool)
+	for _, station := range fromStations {
+		fromCodes[station.TelegramCode] = true
+	}
+
+	toCodes := make(map[string]bool)
+	for _, station := range toStations {
+		toCodes[station.TelegramCode] = true
+	}
+
+	queried := make(map[string]bool) // Key: 出发城市 + 到达城市
+	trains := make(map[string]bool)  // Key: 车次 + 出发站 + 到达站，去掉重复的车次
+	for _, from := range fromStations {
+		for _, to := range toStations {
+			key := cityKey(from) + "-" + cityKey(to)
+			if queried[key] {
+				continue
+			}
+			queried[key] = true
+
+			var result []*common.LeftTicketInfo
+			if result, err = QueryLeftTickets(sess, from.TelegramCode, to.TelegramCode, startDate, student); err != nil {
+				return
+			}
+
+			for _, info := range result {
+				train := info.TrainCode + "-" + info.FromTelegramCode + "-" + info.ToTelegramCode
+				if !fromCodes[info.FromTelegramCode] || !toCodes[info.ToTelegramCode] || trains[train] {
+					continue
+				}
+				trains[train] = true
+
+				infos = append(infos, info)
+			}
+		}
+	}
+
+	return
+}
+
+// FprintLeftTickets 以表格形式输出余票信息，trainCodes 中的车次标 *，可候补的车次标 #
+func FprintLeftTickets(w io.Writer, from, to, startDate string, infos []*common.LeftTicketInfo, trainCodes []string) {
+	fmt.Fprintln(w, strings.Repeat("-", 100))
+	fmt.Fprintf(w, "出发站: %s, 到达站: %s, 出发日期: %s（标 * 车次为待购买车次，标 # 为可候补车次）\n", from, to, startDate)
+	fmt.Fprintf(w, "%-6s%-8s%-6s%-8s%-6s%-7s%-8s%-8s%-6s%-6s%-6s%-6s%-7s%-7s%-7s%-7s%-7s%-7s%-7s%-7s\n",
+		"  车次", "出发站", "出发时间", "到达站", "到达时间", "历时", "始发站", "终到站",
+		"商务座", "特等座", "一等座", "二等座", "高级软卧", "软卧", "动卧", "硬卧", "软座", "硬座", "无座", "其他",
+	)
+
+	for _, leftTicketInfo := range infos {
+		trainCode := strings.ToUpper(leftTicketInfo.TrainCode)
+
+		// 筛选车次
+		if inStringArray(trainCode, trainCodes) {
+			trainCode = "*" + trainCode
+		} else {
+			trainCode = " " + trainCode
+		}
+
+		// 是否可以候补
+		if leftTicketInfo.CanCandidate() {
+			trainCode = "#" + trainCode
+		} else {
+			trainCode = " " + trainCode
+		}
+
+		// 每个汉字宽度约等于 2 个数字或字母，站点名最长五个汉字
+		f := fmt.Sprintf("%%-8s%%-%ds%%-9s%%-%ds%%-9s%%-9s%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds\n",
+			11-utf8.RuneCountInString(leftTicketInfo.From),
+			11-utf8.RuneCountInString(leftTicketInfo.To),
+			11-utf8.RuneCountInString(leftTicketInfo.Start),
+			11-utf8.RuneCountInString(leftTicketInfo.End),
+			9-countHan(leftTicketInfo.ShangWuZuo),
+			9-countHan(leftTicketInfo.TeDengZuo),
+			9-countHan(leftTicketInfo.YiDengZuo),
+			9-countHan(leftTicketInfo.ErDengZuo),
+			9-countHan(leftTicketInfo.GaoJiRuanWo),
+			9-countHan(leftTicketInfo.RuanWo),
+			9-countHan(leftTicketInfo.DongWo),
+			9-countHan(leftTicketInfo.YingWo),
+			9-countHan(leftTicketInfo.RuanZuo),
+			9-countHan(leftTicketInfo.YingZuo),
+			9-countHan(leftTicketInfo.WuZuo),
+			9-countHan(leftTicketInfo.QiTa),
+		)
+		fmt.Fprintf(w, f,
+			trainCode,
+			leftTicketInfo.From,
+			leftTicketInfo.StartTime,
+			leftTicketInfo.To,
+			leftTicketInfo.ArriveTime,
+			leftTicketInfo.Duration,
+			leftTicketInfo.Start,
+			leftTicketInfo.End,
+			leftTicketInfo.ShangWuZuo,
+			leftTicketInfo.TeDengZuo,
+			leftTicketInfo.YiDengZuo,
+			leftTicketInfo.ErDengZuo,
+			leftTicketInfo.GaoJiRuanWo,
+			leftTicketInfo.RuanWo,
+			leftTicketInfo.DongWo,
+			leftTicketInfo.YingWo,
+			leftTicketInfo.RuanZuo,
+			leftTicketInfo.YingZuo,
+			leftTicketInfo.WuZuo,
+			leftTicketInfo.QiTa,
+		)
+
+		// 查询过票价时，另起一行输出各座席的票价
+		if prices := priceSummary(leftTicketInfo); prices != "" {
+			fmt.Fprintf(w, "        票价: %s\n", prices)
+		}
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", 100))
+}
+
+// priceSummary 各座席的票价，如 二等座 ¥314.0，一等座 ¥498.0，没有票价时为空
+func priceSummary(info *common.LeftTicketInfo) string {
+	var arr []string
+	for i, price := range info.Prices {
+		if price > 0 {
+			arr = append(arr, fmt.Sprintf("%s ¥%.1f", common.SeatIndexToSeatName(i), price))
+		}
+	}
+
+	return strings.Join(arr, "，")
+}
+
+// PrintLeftTickets 以表格形式打印余票信息
+func PrintLeftTickets(from, to, startDate string, infos []*common.LeftTicketInfo, trainCodes []string) {
+	FprintLeftTickets(os.Stdout, from, to, startDate, infos, trainCodes)
+}
+
+// passengerTickets 任务的前 n 个乘客购买 seatIndex 座席的乘客信息
+func passengerTickets(task *worker.Task, n, seatIndex int) (passengers common.PassengerTicketInfos) {
+	for i, passenger := range task.Passengers[:n] {
+		passengers = append(passengers, &common.PassengerTicketInfo{
+			PassengerInfo: *passenger,
+			TicketType:    task.TicketTypes[i],
+			SeatType:      common.SeatIndexToSeatType(seatIndex),
+			BedPos:        0,
+		})
+	}
+
+	return
+}
+
+func QueryLeftTicket(sess *session.Session, task *worker.Task) (err error) {
 	if len(task.StartDates) != len(task.SaleTimes) {
 		return errors.New("len of start_dates/saletimes not match")
 	}
 
-	const (
-		url     = "https://%s/otn/%s?leftTicketDTO.train_date=%s&leftTicketDTO.from_station=%s&leftTicketDTO.to_station=%s&purpose_codes=ADULT"
-		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
-	)
-	now := time.Now()
+	now := clock.Now()
 	for i, startDate := range task.StartDates {
 		if i >= len(task.SaleTimes) || now.Before(task.SaleTimes[i]) {
 			logger.Info("未到开售时间，略过此日期...",
@@ -70,145 +288,36 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 			zap.String("出发日期", startDate),
 		)
 
-		req, _ := http.NewRequest("GET", fmt.Sprintf(url, cdn.GetCDN(), leftTicketURL, startDate, task.FromTelegramCode, task.ToTelegramCode), nil)
-		req.Header.Set("Referer", referer)
-		httpcli.DefaultHeaders(req)
-
-		var (
-			body       []byte
-			statusCode int
-		)
-		body, statusCode, err = httpcli.DoHttp(req, jar)
-		if err != nil {
-			logger.Error("获取余票查询 URL 错误", zap.Error(err))
-
-			return
-		} else if statusCode != http.StatusOK {
-			logger.Error("获取余票查询 URL 失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
-
-			return errors.New("get left ticket url failure")
-		}
-
-		type LeftTicketData struct {
-			Result []string `json:"result"`
-		}
-
-		type LeftTicketResult struct {
-			Data LeftTicketData `json:"data"`
-		}
-		result := LeftTicketResult{}
-		if err = json.Unmarshal(body, &result); err != nil {
-			logger.Error("解析余票信息错误", zap.ByteString("res", body), zap.Error(err))
-
+		var infos []*common.LeftTicketInfo
+		if infos, err = QueryStationsLeftTickets(sess, task.FromStations, task.ToStations, startDate, task.Student); err != nil {
 			return
 		}
 
 		// 仅查询
 		if task.QueryOnly {
-			fmt.Println(strings.Repeat("-", 100))
-			fmt.Printf("出发站: %s, 到达站: %s, 出发日期: %s（标 * 车次为待购买车次，标 # 为可候补车次）\n", task.From, task.To, startDate)
-			fmt.Printf("%-6s%-8s%-6s%-8s%-6s%-7s%-8s%-8s%-6s%-6s%-6s%-6s%-7s%-7s%-7s%-7s%-7s%-7s%-7s%-7s\n",
-				"  车次", "出发站", "出发时间", "到达站", "到达时间", "历时", "始发站", "终到站",
-				"商务座", "特等座", "一等座", "二等座", "高级软卧", "软卧", "动卧", "硬卧", "软座", "硬座", "无座", "其他",
-			)
-		}
-
-		for _, row := range result.Data.Result {
-			var leftTicketInfo *common.LeftTicketInfo
-			if leftTicketInfo, err = parseLeftTicketInfo(row); err != nil || leftTicketInfo == nil {
-				logger.Error("解析余票行信息错误", zap.String("行信息", row), zap.Error(err))
-
-				continue
+			if task.MaxPrice > 0 || len(task.SeatMaxPrices) > 0 || task.MaxUpgradePrice > 0 {
+				AttachTicketPrices(sess, infos, startDate)
 			}
 
-			trainCode := strings.ToUpper(leftTicketInfo.TrainCode)
-
-			// 仅查询
-			if task.QueryOnly {
+			if err = WriteLeftTickets(os.Stdout, task.QueryFormat, task.From, task.To, startDate, infos, targetTrainCodes(task, infos)); err != nil {
+				logger.Error("输出余票信息错误", zap.Error(err))
+			}
 
-				// 筛选车次
-				if inStringArray(trainCode, task.TrainCodes) {
-					trainCode = "*" + trainCode
-				} else {
-					trainCode = " " + trainCode
+			if task.Transfer != nil {
+				if _, err = queryTransfer(sess, task, startDate); err != nil {
+					logger.Error("查询中转方案错误", zap.Error(err))
 				}
+			}
 
-				// 是否可以候补
-				if leftTicketInfo.CanCandidate() {
-					trainCode = "#" + trainCode
-				} else {
-					trainCode = " " + trainCode
-				}
+			time.Sleep(time.Second)
+			continue
+		}
 
-				// 每个汉字宽度约等于 2 个数字或字母，站点名最长五个汉字
-				f := fmt.Sprintf("%%-8s%%-%ds%%-9s%%-%ds%%-9s%%-9s%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds%%-%ds\n",
-					11-utf8.RuneCountInString(leftTicketInfo.From),
-					11-utf8.RuneCountInString(leftTicketInfo.To),
-					11-utf8.RuneCountInString(leftTicketInfo.Start),
-					11-utf8.RuneCountInString(leftTicketInfo.End),
-					9-countHan(leftTicketInfo.ShangWuZuo),
-					9-countHan(leftTicketInfo.TeDengZuo),
-					9-countHan(leftTicketInfo.YiDengZuo),
-					9-countHan(leftTicketInfo.ErDengZuo),
-					9-countHan(leftTicketInfo.GaoJiRuanWo),
-					9-countHan(leftTicketInfo.RuanWo),
-					9-countHan(leftTicketInfo.DongWo),
-					9-countHan(leftTicketInfo.YingWo),
-					9-countHan(leftTicketInfo.RuanZuo),
-					9-countHan(leftTicketInfo.YingZuo),
-					9-countHan(leftTicketInfo.WuZuo),
-					9-countHan(leftTicketInfo.QiTa),
-				)
-				fmt.Printf(f,
-					trainCode,
-					leftTicketInfo.From,
-					leftTicketInfo.StartTime,
-					leftTicketInfo.To,
-					leftTicketInfo.ArriveTime,
-					leftTicketInfo.Duration,
-					leftTicketInfo.Start,
-					leftTicketInfo.End,
-					leftTicketInfo.ShangWuZuo,
-					leftTicketInfo.TeDengZuo,
-					leftTicketInfo.YiDengZuo,
-					leftTicketInfo.ErDengZuo,
-					leftTicketInfo.GaoJiRuanWo,
-					leftTicketInfo.RuanWo,
-					leftTicketInfo.DongWo,
-					leftTicketInfo.YingWo,
-					leftTicketInfo.RuanZuo,
-					leftTicketInfo.YingZuo,
-					leftTicketInfo.WuZuo,
-					leftTicketInfo.QiTa,
-				)
-
-				// logger.Debug("列车余票查询结果",
-				// 	zap.String("出发日期", startDate),
-				// 	zap.String("车次", leftTicketInfo.TrainCode),
-				// 	zap.String("始发站", leftTicketInfo.Start),
-				// 	zap.String("终到站", leftTicketInfo.End),
-				// 	zap.String("出发站", leftTicketInfo.From),
-				// 	zap.String("到达站", leftTicketInfo.To),
-				// 	zap.String("出发时间", leftTicketInfo.StartTime),
-				// 	zap.String("到达时间", leftTicketInfo.ArriveTime),
-				// 	zap.String("历时", leftTicketInfo.Duration),
-				// 	zap.String("商务座", leftTicketInfo.ShangWuZuo),
-				// 	zap.String("特等座", leftTicketInfo.TeDengZuo),
-				// 	zap.String("一等座", leftTicketInfo.YiDengZuo),
-				// 	zap.String("二等座/二等包座", leftTicketInfo.ErDengZuo),
-				// 	zap.String("高级软卧", leftTicketInfo.GaoJiRuanWo),
-				// 	zap.String("软卧/一等卧", leftTicketInfo.RuanWo),
-				// 	zap.String("动卧", leftTicketInfo.DongWo),
-				// 	zap.String("硬卧/二等卧", leftTicketInfo.YingWo),
-				// 	zap.String("软座", leftTicketInfo.RuanZuo),
-				// 	zap.String("硬座", leftTicketInfo.YingZuo),
-				// 	zap.String("无座", leftTicketInfo.WuZuo),
-				// 	zap.String("其他", leftTicketInfo.QiTa),
-				// )
-
-				// 仅查询不下单
-				continue
-			}
+		// 本轮查询过的更长区间余票，多个车次买长乘短时共用
+		queried := make(leftTicketsCache)
+
+		for _, leftTicketInfo := range infos {
+			trainCode := strings.ToUpper(leftTicketInfo.TrainCode)
 
 			////////////////////////////////////////////////////////////////////////////////////////////////////////////////////////
 			// 以下为下单
@@ -220,7 +329,7 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 			}
 
 			// 筛选车次
-			if !inStringArray(trainCode, task.TrainCodes) {
+			if !task.IsTarget(leftTicketInfo) {
 				continue
 			}
 
@@ -249,13 +358,7 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 					)
 
 					// 候补时设置填了多少乘客就候补多少张票，没有先后顺序之分
-					for _, passenger := range task.Passengers {
-						passengers = append(passengers, &common.PassengerTicketInfo{
-							PassengerInfo: *passenger,
-							SeatType:      common.SeatIndexToSeatType(seatIndex),
-							BedPos:        0,
-						})
-					}
+					passengers = passengerTickets(task, len(task.Passengers), seatIndex)
 				} else if task.AllowPartly { // 允许提交部分乘客
 					somePassengers := task.Passengers[:leftTickets]
 
@@ -269,13 +372,7 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 						zap.Array("乘客", somePassengers),
 					)
 
-					for _, passenger := range somePassengers {
-						passengers = append(passengers, &common.PassengerTicketInfo{
-							PassengerInfo: *passenger,
-							SeatType:      common.SeatIndexToSeatType(seatIndex),
-							BedPos:        0,
-						})
-					}
+					passengers = passengerTickets(task, len(somePassengers), seatIndex)
 				} else if !task.AllowCandidate {
 					logger.Debug("乘车人数比余票数量多，并且已设置不接受候补，忽略此车次和座席...",
 						zap.String("车次", trainCode),
@@ -287,7 +384,12 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 					continue
 				}
 
-				if err = order.DoOrder(jar, task, leftTicketInfo, startDate, trainCode, seatIndex, passengers); err != nil {
+				// 有余票时才查询票价，票价超过最高票价时忽略
+				if !withinMaxPrice(sess, task, leftTicketInfo, startDate, seatIndex) {
+					continue
+				}
+
+				if err = order.DoOrder(sess, task, leftTicketInfo, startDate, trainCode, seatIndex, passengers); err != nil {
 					logger.Warn("由于下单或候补失败，将此车次加入小黑屋",
 						zap.Int64("任务 ID", task.TaskID),
 						zap.String("车次", trainCode),
@@ -299,20 +401,27 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 					continue
 				}
 
-				task.Done <- struct{}{}
+				orderSucceeded(task)
 				return
 			}
-		}
 
-		// logger.Debug("查询列车余票结果",
-		// 	zap.String("出发站", task.From),
-		// 	zap.String("到达站", task.To),
-		// 	zap.String("出发日期", startDate),
-		// 	zap.Strings("result", result.Data.Result),
-		// )
+			// 原区间没有下单成功时，尝试购买同一车次的更长区间
+			if task.LongerSegment && task.LongerSegmentDue(trainCode, startDate, time.Now()) &&
+				orderLongerSegment(sess, task, leftTicketInfo, startDate, queried) {
+				orderSucceeded(task)
+				return
+			}
+		}
 
-		if task.QueryOnly {
-			fmt.Println(strings.Repeat("-", 100))
+		// 直达车次没有下单成功时查询中转方案，有次数和频率限制
+		if task.Transfer != nil && task.Transfer.DirectFailed(startDate, time.Now()) {
+			var done bool
+			if done, err = queryTransfer(sess, task, startDate); done {
+				task.Done <- struct{}{}
+				return
+			} else if err != nil {
+				logger.Error("查询中转方案错误", zap.Error(err))
+			}
 		}
 
 		time.Sleep(time.Second)
@@ -320,3 +429,29 @@ func QueryLeftTicket(jar *cookiejar.Jar, task *worker.Task) (err error) {
 
 	return
 }
+
+// targetTrainCodes 查询结果中待购买的车次
+func targetTrainCodes(task *worker.Task, infos []*common.LeftTicketInfo) (trainCodes []string) {
+	for _, info := range infos {
+		if task.IsTarget(info) {
+			trainCodes = append(trainCodes, strings.ToUpper(info.TrainCode))
+		}
+	}
+
+	return
+}
+
+// orderSucceeded 下单成功：往返票去程下单成功时继续抢返程，否则结束任务
+func orderSucceeded(task *worker.Task) {
+	if task.SwitchToBack() {
+		logger.Info("往返票去程下单成功，开始抢返程",
+			zap.String("出发站", task.From),
+			zap.String("到达站", task.To),
+			zap.Strings("返程日期", task.StartDates),
+		)
+
+		return
+	}
+
+	task.Done <- struct{}{}
+}
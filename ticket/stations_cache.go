@@ -0,0 +1,155 @@
+package ticket
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"gogo12306/common"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// 内置的站点列表快照，无法从 12306 获取且没有本地缓存时使用
+// 执行 gogo12306 stations update -o ticket/snapshot/stations.json 更新后重新编译
+//
+//go:embed snapshot/stations.json
+var stationsSnapshot []byte
+
+// DefaultStationsCacheMaxAge 站点列表缓存的默认有效时间
+const DefaultStationsCacheMaxAge = time.Hour * 24
+
+// StationsCache 站点列表缓存文件的内容
+type StationsCache struct {
+	UpdatedAt time.Time             `json:"updated_at"` // 从 12306 获取的时间
+	Stations  []*common.StationInfo `json:"stations"`
+}
+
+// SaveStationsCache 保存站点列表和开售时间
+func SaveStationsCache(path string, stations *common.Stations) (err error) {
+	cache := StationsCache{
+		UpdatedAt: time.Now(),
+		Stations:  stations.All(),
+	}
+
+	var data []byte
+	if data, err = json.MarshalIndent(&cache, "", "  "); err != nil {
+		return
+	}
+
+	if dir := filepath.Dir(path); dir != "" {
+		if err = os.MkdirAll(dir, 0755); err != nil {
+			return
+		}
+	}
+
+	// 先写临时文件再改名，避免写到一半时程序退出导致缓存损坏
+	tmp := path + ".tmp"
+	if err = ioutil.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func parseStationsCache(data []byte) (cache *StationsCache, err error) {
+	cache = &StationsCache{}
+	if err = json.Unmarshal(data, cache); err != nil {
+		return nil, err
+	}
+
+	if len(cache.Stations) == 0 {
+		return nil, errors.New("empty stations cache")
+	}
+
+	return
+}
+
+// LoadStationsCache 读取站点列表缓存
+func LoadStationsCache(path string) (cache *StationsCache, err error) {
+	var data []byte
+	if data, err = ioutil.ReadFile(path); err != nil {
+		return
+	}
+
+	return parseStationsCache(data)
+}
+
+func addStations(stations *common.Stations, cache *StationsCache) {
+	for _, station := range cache.Stations {
+		stations.Add(station)
+	}
+}
+
+// InitStations 初始化站点列表和开售时间
+// 本地缓存未过期时直接使用缓存，否则从 12306 获取并更新缓存；
+// 获取失败时使用本地缓存（即使已过期），没有缓存时使用内置快照
+func InitStations(sess *session.Session) (err error) {
+	cfg := &sess.Cfg.Stations
+
+	maxAge := time.Duration(cfg.MaxAgeHours) * time.Hour
+	if maxAge <= 0 {
+		maxAge = DefaultStationsCacheMaxAge
+	}
+
+	var cache *StationsCache
+	if cfg.CachePath != "" {
+		if cache, err = LoadStationsCache(cfg.CachePath); err == nil && time.Since(cache.UpdatedAt) < maxAge {
+			logger.Debug("使用本地缓存的站点列表",
+				zap.String("path", cfg.CachePath),
+				zap.Time("更新时间", cache.UpdatedAt),
+				zap.Int("站点数量", len(cache.Stations)),
+			)
+
+			addStations(sess.Stations, cache)
+			return nil
+		}
+	}
+
+	var stations *common.Stations
+	if stations, err = FetchStations(sess); err == nil {
+		addStations(sess.Stations, &StationsCache{Stations: stations.All()})
+
+		if cfg.CachePath != "" {
+			if e := SaveStationsCache(cfg.CachePath, stations); e != nil {
+				logger.Warn("保存站点列表缓存错误", zap.String("path", cfg.CachePath), zap.Error(e))
+			}
+		}
+
+		return nil
+	}
+
+	if cache != nil {
+		logger.Warn("获取站点列表失败，使用已过期的本地缓存",
+			zap.String("path", cfg.CachePath),
+			zap.Time("更新时间", cache.UpdatedAt),
+			zap.Error(err),
+		)
+
+		addStations(sess.Stations, cache)
+		return nil
+	}
+
+	if cache, e := parseStationsCache(stationsSnapshot); e == nil {
+		logger.Warn("获取站点列表失败，使用内置的站点列表快照",
+			zap.Time("更新时间", cache.UpdatedAt),
+			zap.Int("站点数量", len(cache.Stations)),
+			zap.Error(err),
+		)
+
+		addStations(sess.Stations, cache)
+		return nil
+	}
+
+	logger.Error("获取站点列表失败，且没有本地缓存和内置快照",
+		zap.String("path", cfg.CachePath),
+		zap.Error(err),
+	)
+
+	return
+}
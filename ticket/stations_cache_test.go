@@ -0,0 +1,69 @@
+package ticket_test
+
+import (
+	"gogo12306/cdn"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"gogo12306/ticket"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestInitStationsOffline(t *testing.T) {
+	logger.Init(true, "test.log", "info", 1024, 7)
+
+	// 无法连接的地址，只能使用缓存或内置快照
+	pool := cdn.NewPool()
+	pool.SetEndpoint("127.0.0.1:1", "127.0.0.1:1")
+
+	cfg := &config.Config{}
+	cfg.Stations.CachePath = filepath.Join(t.TempDir(), "stations.json")
+
+	newSession := func() *session.Session {
+		sess, err := session.New(cfg, &cfg.Login, pool, common.NewStations())
+		if err != nil {
+			t.Fatal(err.Error())
+		}
+
+		return sess
+	}
+
+	// 没有缓存也没有网络时使用内置快照
+	sess := newSession()
+	if err := ticket.InitStations(sess); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if sess.Stations.Len() == 0 {
+		t.Fatal("snapshot is empty")
+	}
+
+	// 有缓存时使用缓存
+	stations := common.NewStations()
+	stations.Add(&common.StationInfo{ID: 1, StationName: "测试站", TelegramCode: "CSZ", SaleTime: time.Hour * 15})
+	if err := ticket.SaveStationsCache(cfg.Stations.CachePath, stations); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	cache, err := ticket.LoadStationsCache(cfg.Stations.CachePath)
+	if err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if len(cache.Stations) != 1 || cache.Stations[0].SaleTime != time.Hour*15 || time.Since(cache.UpdatedAt) > time.Minute {
+		t.Errorf("unexpected cache: %+v", cache)
+	}
+
+	cfg.Stations.MaxAgeHours = -1 // 默认 24 小时
+	sess = newSession()
+	if err = ticket.InitStations(sess); err != nil {
+		t.Fatal(err.Error())
+	}
+
+	if sess.Stations.Len() != 1 || sess.Stations.StationTelegramCodeToStationInfo("CSZ") == nil {
+		t.Errorf("cache not used, %d stations", sess.Stations.Len())
+	}
+}
@@ -0,0 +1,220 @@
+package ticket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gogo12306/common"
+	"gogo12306/httpcli"
+	"gogo12306/logger"
+	"gogo12306/session"
+	"gogo12306/worker"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// 票价缓存的有效期
+	ticketPricesCacheTTL = 6 * time.Hour
+
+	// 查询失败的结果也缓存一个查询间隔，避免每轮查询都重复请求查询不到票价的车次
+	ticketPricesErrorTTL = worker.INTERVAL
+)
+
+// https://kyfw.12306.cn/otn/resources/merged/queryLeftTicket_end_js.js
+// 票价查询结果中各座席的 Key，顺序与 LeftTicketsCount 一致
+var seatPriceKeys = [][]string{
+	{"A9", "9"}, // 商务座
+	{"P"},       // 特等座
+	{"M"},       // 一等座
+	{"O"},       // 二等座
+	{"A6"},      // 高级软卧
+	{"A4"},      // 软卧
+	{"F"},       // 动卧
+	{"A3"},      // 硬卧
+	{"A2"},      // 软座
+	{"A1"},      // 硬座
+	{"WZ"},      // 无座
+	{},          // 其他
+}
+
+// QueryTicketPrices 查询车次各座席的票价，顺序与 LeftTicketsCount 一致，结果缓存在会话中，不需要登录
+// startDate 为出发日期，列车的始发日期不同时使用始发日期；查询失败时也会短暂缓存错误
+func QueryTicketPrices(sess *session.Session, info *common.LeftTicketInfo, startDate string) (prices []float64, err error) {
+	const (
+		url     = "https://%s/otn/leftTicket/queryTicketPrice?train_no=%s&from_station_no=%s&to_station_no=%s&seat_types=%s&train_date=%s"
+		referer = "https://kyfw.12306.cn/otn/leftTicket/init"
+	)
+
+	trainDate := info.StartTrainDate
+	if trainDate == "" {
+		trainDate = startDate
+	}
+
+	key := strings.Join([]string{info.TrainNumber, info.FromStationNo, info.ToStationNo, info.SeatTypes, trainDate}, "_")
+
+	if cached, ok := sess.TicketPrices.Get(key); ok {
+		if e, ok := cached.(error); ok {
+			return nil, e
+		}
+
+		return cached.([]float64), nil
+	}
+
+	defer func() {
+		if err != nil {
+			sess.TicketPrices.Set(key, err, ticketPricesErrorTTL)
+		}
+	}()
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetCDN(), info.TrainNumber, info.FromStationNo, info.ToStationNo, info.SeatTypes, trainDate), nil)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
+	if err != nil {
+		logger.Error("查询票价错误", zap.String("车次", info.TrainCode), zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("查询票价失败", zap.String("车次", info.TrainCode), zap.Int("statusCode", statusCode), zap.ByteString("body", body))
+
+		return nil, errors.New("query ticket price failure")
+	}
+
+	type TicketPriceResponse struct {
+		Data     map[string]interface{} `json:"data"`
+		Status   bool                   `json:"status"`
+		Messages []string               `json:"messages"`
+	}
+
+	response := TicketPriceResponse{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		logger.Error("解析票价错误", zap.ByteString("body", body), zap.Error(err))
+
+		return
+	} else if !response.Status {
+		logger.Error("查询票价失败", zap.String("车次", info.TrainCode), zap.Strings("错误消息", response.Messages))
+
+		return nil, errors.New(strings.Join(response.Messages, ""))
+	}
+
+	// 票价格式如 ¥663.0
+	for _, keys := range seatPriceKeys {
+		var price float64
+		for _, k := range keys {
+			if text, ok := response.Data[k].(string); ok {
+				if price, err = strconv.ParseFloat(strings.TrimPrefix(text, "¥"), 64); err != nil {
+					logger.Error("解析票价错误", zap.String("车次", info.TrainCode), zap.String("座席", k), zap.String("票价", text))
+
+					return nil, err
+				}
+
+				break
+			}
+		}
+
+		prices = append(prices, price)
+	}
+
+	sess.TicketPrices.Set(key, prices, ticketPricesCacheTTL)
+
+	return
+}
+
+// AttachTicketPrices 查询并设置余票信息中各座席的票价，个别车次查询失败时忽略
+func AttachTicketPrices(sess *session.Session, infos []*common.LeftTicketInfo, startDate string) {
+	for _, info := range infos {
+		if len(info.Prices) > 0 {
+			continue
+		}
+
+		prices, err := QueryTicketPrices(sess, info, startDate)
+		if err != nil {
+			logger.Warn("查询票价失败，忽略此车次的票价", zap.String("车次", info.TrainCode), zap.Error(err))
+
+			continue
+		}
+
+		info.Prices = prices
+	}
+}
+
+// withinMaxPrice 座席的票价是否符合任务的票价限制，没有设置票价限制时总是 true：
+// 不超过座席的最高票价；比 seats 中排在前面的座席最低的票价贵出不超过 max_upgrade_price
+// 查询不到票价时无法判断，记录警告并允许下单，以免因为票价接口出错错过有余票的车次
+func withinMaxPrice(sess *session.Session, task *worker.Task, info *common.LeftTicketInfo, startDate string, seatIndex int) bool {
+	maxPrice := task.MaxPriceOf(seatIndex)
+	upgradeFrom := upgradeFromSeats(task, seatIndex)
+	if maxPrice <= 0 && (task.MaxUpgradePrice <= 0 || len(upgradeFrom) == 0) {
+		return true
+	}
+
+	AttachTicketPrices(sess, []*common.LeftTicketInfo{info}, startDate)
+
+	price := info.Price(seatIndex)
+	if price <= 0 {
+		logger.Warn("没有查询到票价，不检查票价限制",
+			zap.String("车次", info.TrainCode),
+			zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+		)
+
+		return true
+	}
+
+	if maxPrice > 0 && price > maxPrice {
+		logger.Debug("票价超过最高票价，忽略此车次和座席",
+			zap.String("车次", info.TrainCode),
+			zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+			zap.Float64("票价", price),
+			zap.Float64("最高票价", maxPrice),
+		)
+
+		return false
+	}
+
+	if task.MaxUpgradePrice <= 0 {
+		return true
+	}
+
+	// 与排在前面的座席中票价最低的比较，前面的座席都查询不到票价时不限制
+	var base float64
+	for _, index := range upgradeFrom {
+		if p := info.Price(index); p > 0 && (base <= 0 || p < base) {
+			base = p
+		}
+	}
+
+	if base > 0 && price-base > task.MaxUpgradePrice {
+		logger.Debug("升级座席的差价超过限制，忽略此车次和座席",
+			zap.String("车次", info.TrainCode),
+			zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+			zap.Float64("票价", price),
+			zap.Float64("差价", price-base),
+			zap.Float64("最高差价", task.MaxUpgradePrice),
+		)
+
+		return false
+	}
+
+	return true
+}
+
+// upgradeFromSeats seats 中排在座席前面的座席
+func upgradeFromSeats(task *worker.Task, seatIndex int) []int {
+	for i, index := range task.SeatIndices {
+		if index == seatIndex {
+			return task.SeatIndices[:i]
+		}
+	}
+
+	return nil
+}
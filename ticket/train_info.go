@@ -3,8 +3,10 @@ package ticket
 import (
 	"encoding/json"
 	"errors"
+	"gogo12306/common"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
+	"gogo12306/session"
 	"net/http"
 	"path"
 	"regexp"
@@ -15,38 +17,23 @@ import (
 	"go.uber.org/zap"
 )
 
-type StationInfo struct {
-	ID           int
-	TelegramCode string        // 电报码
-	StationName  string        // 站点名
-	PinYin       string        // 拼音
-	PY           string        // 拼音首字母
-	PYCode       string        // 拼音码
-	SaleTime     time.Duration // 站点开售时间
-}
-
-var stations map[int]*StationInfo
-
-func init() {
-	stations = make(map[int]*StationInfo)
-}
-
-func setHeaders(req *http.Request) {
+func setHeaders(sess *session.Session, req *http.Request) {
 	const userAgent = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/96.0.4664.110 Safari/537.36 Edg/96.0.1054.62"
 	req.Header.Set("User-Agent", userAgent)
 	req.Header.Set("Cache-Control", "no-cache")
 	req.Header.Set("Connection", "keep-alive")
 	req.Header.Set("Host", "www.12306.cn")
-	req.URL.Host = "www.12306.cn"
+	req.URL.Host = sess.CDN.GetWWW()
 	req.Host = "www.12306.cn"
 }
 
-func InitStations() (err error) {
+// FetchStations 从 12306 获取站点列表和开售时间
+func FetchStations(sess *session.Session) (stations *common.Stations, err error) {
 	const (
 		urlHomepage = "https://www.12306.cn/index/index.html"
 	)
 	req, _ := http.NewRequest("GET", urlHomepage, nil)
-	setHeaders(req)
+	setHeaders(sess, req)
 
 	var (
 		body       []byte
@@ -60,7 +47,7 @@ func InitStations() (err error) {
 	} else if statusCode != http.StatusOK {
 		logger.Error("获取主页失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
 
-		return errors.New("get homepage failure")
+		return nil, errors.New("get homepage failure")
 	}
 
 	var re1, re2 *regexp.Regexp
@@ -80,14 +67,14 @@ func InitStations() (err error) {
 	if len(body1) != 2 {
 		logger.Error("匹配正则表达式 1 失败", zap.ByteString("body", body), zap.String("re", re1.String()))
 
-		return errors.New("regexp 1 failure")
+		return nil, errors.New("regexp 1 failure")
 	}
 
 	body2 := re2.FindSubmatch(body)
 	if len(body2) != 2 {
 		logger.Error("匹配正则表达式 2 失败", zap.ByteString("body", body), zap.String("re", re2.String()))
 
-		return errors.New("regexp 2 failure")
+		return nil, errors.New("regexp 2 failure")
 	}
 
 	urlStationName := path.Join("www.12306.cn/index", string(body1[1]))
@@ -101,7 +88,7 @@ func InitStations() (err error) {
 	//////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 	req, _ = http.NewRequest("GET", "https://"+urlStationName, nil)
-	setHeaders(req)
+	setHeaders(sess, req)
 	req.Header.Set("Referer", urlHomepage)
 
 	body, statusCode, err = httpcli.DoHttp(req, nil)
@@ -112,7 +99,7 @@ func InitStations() (err error) {
 	} else if statusCode != http.StatusOK {
 		logger.Error("获取站点列表失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
 
-		return errors.New("get stations failure")
+		return nil, errors.New("get stations failure")
 	}
 
 	const (
@@ -124,36 +111,48 @@ func InitStations() (err error) {
 		!strings.HasSuffix(stationList, suffix1) {
 		logger.Error("获取到的不是站点列表", zap.ByteString("body", body))
 
-		return errors.New("not station list")
+		return nil, errors.New("not station list")
 	}
 	stationList = strings.TrimSuffix(strings.TrimPrefix(stationList, prefix1), suffix1)
 
+	stations = common.NewStations()
+
 	for _, row := range strings.Split(stationList, "@") {
-		// 拼音码|站点名|电报码|拼音|拼音首字母|ID
-		// gzh|广州|GZQ|guangzhou|gz|33
-		// gzn|广州南|IZQ|guangzhounan|gzn|5
+		// 拼音码|站点名|电报码|拼音|拼音首字母|ID|城市代码|城市名
+		// gzh|广州|GZQ|guangzhou|gz|33|1014|广州
+		// gzn|广州南|IZQ|guangzhounan|gzn|5|1014|广州
+		// 旧版本的站点列表没有城市代码和城市名
 		fields := strings.Split(row, "|")
-
-		var id int
-		if id, err = strconv.Atoi(fields[5]); err != nil {
-			logger.Error("解析站点信息错误", zap.Strings("fields", fields), zap.Error(err))
+		if len(fields) < 6 {
+			logger.Error("解析站点信息错误", zap.Strings("fields", fields))
 
 			continue
 		}
 
-		if stations[id] != nil {
-			logger.Error("站点已存在", zap.Int("id", id), zap.Strings("fields", fields))
+		var cityName string
+		if len(fields) > 7 {
+			cityName = fields[7]
+		}
+
+		var id int
+		if id, err = strconv.Atoi(fields[5]); err != nil {
+			logger.Error("解析站点信息错误", zap.Strings("fields", fields), zap.Error(err))
 
 			continue
 		}
 
-		stations[id] = &StationInfo{
+		if !stations.Add(&common.StationInfo{
 			ID:           id,
 			TelegramCode: fields[2],
 			StationName:  fields[1],
 			PinYin:       fields[3],
 			PY:           fields[4],
 			PYCode:       fields[0],
+			CityName:     cityName,
+		}) {
+			logger.Error("站点已存在", zap.Int("id", id), zap.Strings("fields", fields))
+
+			continue
 		}
 	}
 
@@ -162,7 +161,7 @@ func InitStations() (err error) {
 	//////////////////////////////////////////////////////////////////////////////////////////////////////////////
 
 	req, _ = http.NewRequest("GET", "https://"+urlQSS, nil)
-	setHeaders(req)
+	setHeaders(sess, req)
 	req.Header.Set("Referer", urlHomepage)
 
 	body, statusCode, err = httpcli.DoHttp(req, nil)
@@ -173,7 +172,7 @@ func InitStations() (err error) {
 	} else if statusCode != http.StatusOK {
 		logger.Error("获取站点开售时间列表失败", zap.Int("statusCode", statusCode), zap.ByteString("body", body))
 
-		return errors.New("get stations sale list failure")
+		return nil, errors.New("get stations sale list failure")
 	}
 
 	const (
@@ -189,7 +188,7 @@ func InitStations() (err error) {
 	if !strings.HasPrefix(saleList, prefix2) {
 		logger.Error("获取到的不是站点开售时间列表", zap.ByteString("body", body))
 
-		return errors.New("not sale list")
+		return nil, errors.New("not sale list")
 	}
 	saleList = strings.TrimPrefix(saleList, prefix2)
 
@@ -201,7 +200,7 @@ func InitStations() (err error) {
 	}
 
 	for stationName, saleTime := range saleMap {
-		if stationInfo := StationNameToStationInfo(stationName); stationInfo == nil {
+		if stationInfo := stations.StationNameToStationInfo(stationName); stationInfo == nil {
 			// logger.Error("没有找到站点信息", zap.String("站点", stationName))
 
 			continue
@@ -214,25 +213,6 @@ func InitStations() (err error) {
 		}
 	}
 
-	return
-}
-
-func StationNameToStationInfo(stationName string) (stationInfo *StationInfo) {
-	for _, station := range stations {
-		if station.StationName == stationName {
-			return station
-		}
-	}
-
-	return nil
-}
-
-func StationTelegramCodeToStationInfo(telegramCode string) (stationInfo *StationInfo) {
-	for _, station := range stations {
-		if station.TelegramCode == telegramCode {
-			return station
-		}
-	}
-
-	return nil
+	// 个别站点解析出错时忽略
+	return stations, nil
 }
@@ -0,0 +1,253 @@
+package ticket
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"gogo12306/common"
+	"gogo12306/httpcli"
+	"gogo12306/logger"
+	"gogo12306/session"
+
+	"go.uber.org/zap"
+)
+
+// 时刻表缓存的有效期，列车时刻很少变化，停运、调图时过期后重新获取
+const trainStopsCacheTTL = 6 * time.Hour
+
+// SearchTrainNo 按车次（如 G1002）查询车次编号（如 6i000G100200），startDate 为列车的始发日期，不需要登录
+func SearchTrainNo(sess *session.Session, trainCode, startDate string) (trainNo string, err error) {
+	const (
+		url     = "https://%s/search/v1/train/search?keyword=%s&date=%s"
+		referer = "https://kyfw.12306.cn/otn/queryTrainInfo/init"
+	)
+
+	trainCode = strings.ToUpper(strings.TrimSpace(trainCode))
+
+	var date time.Time
+	if date, err = time.Parse("2006-01-02", startDate); err != nil {
+		return
+	}
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetSearch(), trainCode, date.Format("20060102")), nil)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+	req.Header.Set("Host", "search.12306.cn")
+	req.Host = "search.12306.cn"
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
+	if err != nil {
+		logger.Error("查询车次编号错误", zap.String("车次", trainCode), zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("查询车次编号失败", zap.String("车次", trainCode), zap.Int("statusCode", statusCode), zap.ByteString("body", body))
+
+		return "", errors.New("search train failure")
+	}
+
+	type TrainSearchItem struct {
+		StationTrainCode string `json:"station_train_code"`
+		TrainNo          string `json:"train_no"`
+		FromStation      string `json:"from_station"`
+		ToStation        string `json:"to_station"`
+	}
+
+	type TrainSearchResponse struct {
+		Data     []TrainSearchItem `json:"data"`
+		Status   bool              `json:"status"`
+		ErrorMsg string            `json:"errorMsg"`
+	}
+
+	response := TrainSearchResponse{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		logger.Error("解析车次编号错误", zap.ByteString("body", body), zap.Error(err))
+
+		return
+	} else if !response.Status {
+		logger.Error("查询车次编号失败", zap.String("车次", trainCode), zap.String("错误消息", response.ErrorMsg))
+
+		return "", errors.New(response.ErrorMsg)
+	}
+
+	// 搜索结果按前缀匹配，如 G100 会匹配到 G1002
+	for _, item := range response.Data {
+		if item.StationTrainCode == trainCode {
+			return item.TrainNo, nil
+		}
+	}
+
+	return "", fmt.Errorf("没有找到 %s 在 %s 开行的车次", trainCode, startDate)
+}
+
+// QueryTrainStops 查询列车的时刻表，结果缓存在会话中，不需要登录
+// trainNo 为车次编号（即 LeftTicketInfo.TrainNumber），startDate 为列车的始发日期，
+// fromTelegramCode、toTelegramCode 为查询区间，可以为空
+func QueryTrainStops(sess *session.Session, trainNo, fromTelegramCode, toTelegramCode, startDate string) (stops common.TrainStops, err error) {
+	key := trainNo + "_" + startDate
+
+	if cached, ok := sess.TrainStops.Get(key); ok {
+		return cached.(common.TrainStops), nil
+	}
+
+	if stops, err = queryByTrainNo(sess, trainNo, fromTelegramCode, toTelegramCode, startDate); err != nil {
+		return
+	}
+
+	sess.TrainStops.Set(key, stops, trainStopsCacheTTL)
+
+	return
+}
+
+func queryByTrainNo(sess *session.Session, trainNo, fromTelegramCode, toTelegramCode, startDate string) (stops common.TrainStops, err error) {
+	const (
+		url     = "https://%s/otn/czxx/queryByTrainNo?train_no=%s&from_station_telecode=%s&to_station_telecode=%s&depart_date=%s"
+		referer = "https://kyfw.12306.cn/otn/queryTrainInfo/init"
+	)
+
+	req, _ := http.NewRequest("GET", fmt.Sprintf(url, sess.CDN.GetCDN(), trainNo, fromTelegramCode, toTelegramCode, startDate), nil)
+	req.Header.Set("Referer", referer)
+	httpcli.DefaultHeaders(req)
+
+	var (
+		body       []byte
+		statusCode int
+	)
+	body, statusCode, err = httpcli.DoHttp(req, sess.Jar)
+	if err != nil {
+		logger.Error("查询列车时刻表错误", zap.String("车次编号", trainNo), zap.Error(err))
+
+		return
+	} else if statusCode != http.StatusOK {
+		logger.Error("查询列车时刻表失败", zap.String("车次编号", trainNo), zap.Int("statusCode", statusCode), zap.ByteString("body", body))
+
+		return nil, errors.New("query train stops failure")
+	}
+
+	type TrainStopItem struct {
+		StationNo    string `json:"station_no"`
+		StationName  string `json:"station_name"`
+		ArriveTime   string `json:"arrive_time"`
+		StartTime    string `json:"start_time"`
+		StopoverTime string `json:"stopover_time"`
+	}
+
+	type TrainStopsData struct {
+		Data []TrainStopItem `json:"data"`
+	}
+
+	type TrainStopsResponse struct {
+		Data     TrainStopsData `json:"data"`
+		Status   bool           `json:"status"`
+		Messages []string       `json:"messages"`
+	}
+
+	response := TrainStopsResponse{}
+	if err = json.Unmarshal(body, &response); err != nil {
+		logger.Error("解析列车时刻表错误", zap.ByteString("body", body), zap.Error(err))
+
+		return
+	} else if !response.Status {
+		logger.Error("查询列车时刻表失败", zap.String("车次编号", trainNo), zap.Strings("错误消息", response.Messages))
+
+		return nil, errors.New(strings.Join(response.Messages, ""))
+	} else if len(response.Data.Data) == 0 {
+		return nil, fmt.Errorf("没有找到车次编号 %s 在 %s 的时刻表", trainNo, startDate)
+	}
+
+	// 时刻比上一个时刻早时说明跨过了零点
+	var day, last int
+	nextDay := func(hhmm string) int {
+		var hours, minutes int
+		if _, err := fmt.Sscanf(hhmm, "%d:%d", &hours, &minutes); err != nil {
+			return day
+		}
+
+		m := hours*60 + minutes
+		if m < last {
+			day++
+		}
+		last = m
+
+		return day
+	}
+
+	for i, item := range response.Data.Data {
+		stop := &common.TrainStop{
+			StationNo:   i + 1,
+			StationName: strings.TrimSpace(item.StationName),
+		}
+		fmt.Sscanf(item.StationNo, "%d", &stop.StationNo)
+		fmt.Sscanf(item.StopoverTime, "%d", &stop.StopMinutes)
+
+		if station := sess.Stations.StationNameToStationInfo(stop.StationName); station != nil {
+			stop.TelegramCode = station.TelegramCode
+		}
+
+		if i > 0 {
+			stop.ArriveTime = item.ArriveTime
+			stop.ArriveDay = nextDay(item.ArriveTime)
+		}
+
+		if i < len(response.Data.Data)-1 {
+			stop.DepartTime = item.StartTime
+			stop.DepartDay = nextDay(item.StartTime)
+		} else {
+			stop.DepartDay = stop.ArriveDay
+		}
+
+		stops = append(stops, stop)
+	}
+
+	return
+}
+
+// FprintTrainStops 输出列车时刻表
+func FprintTrainStops(w io.Writer, trainCode, startDate string, stops common.TrainStops) {
+	fmt.Fprintln(w, strings.Repeat("-", 100))
+	fmt.Fprintf(w, "车次: %s, 始发日期: %s，共 %d 个停靠站\n", trainCode, startDate, len(stops))
+
+	for _, stop := range stops {
+		arrive, depart, stopover := "----", "----", "----"
+		if stop.ArriveTime != "" {
+			arrive = stop.ArriveTime + dayMark(stop.ArriveDay)
+		}
+
+		if stop.DepartTime != "" {
+			depart = stop.DepartTime + dayMark(stop.DepartDay)
+		}
+
+		if stop.ArriveTime != "" && stop.DepartTime != "" {
+			stopover = fmt.Sprintf("%d分钟", stop.StopMinutes)
+		}
+
+		// 站名用全角空格补齐，便于对齐
+		padding := ""
+		if n := 6 - countHan(stop.StationName); n > 0 {
+			padding = strings.Repeat("　", n)
+		}
+
+		fmt.Fprintf(w, "%3d. %s%s 到达 %-10s 出发 %-10s 停车 %s\n",
+			stop.StationNo, stop.StationName, padding, arrive, depart, stopover)
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", 100))
+}
+
+// dayMark 跨天标记，如 +1 为始发后的第二天
+func dayMark(day int) string {
+	if day == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("(+%d)", day)
+}
@@ -0,0 +1,406 @@
+package ticket
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gogo12306/blacklist"
+	"gogo12306/clock"
+	"gogo12306/common"
+	"gogo12306/logger"
+	"gogo12306/notifier"
+	"gogo12306/order"
+	"gogo12306/session"
+	"gogo12306/worker"
+
+	"go.uber.org/zap"
+)
+
+// 默认的中转城市，没有配置中转站时使用站点列表中这些城市的所有站点
+var defaultTransferHubs = []string{
+	"北京", "上海", "广州", "深圳", "武汉", "郑州", "长沙", "南京", "杭州", "西安", "成都", "重庆",
+	"济南", "合肥", "南昌", "福州", "徐州", "天津", "沈阳", "石家庄", "贵阳", "昆明", "南宁", "兰州",
+}
+
+// 默认的换乘时间范围
+const (
+	DefaultTransferMinWait = time.Minute * 30
+	DefaultTransferMaxWait = time.Hour * 4
+)
+
+// 默认的中转方案查询限制
+const (
+	DefaultTransferMaxHubs     = 8               // 最多查询的中转站数量
+	DefaultTransferAfterCycles = 3               // 直达车次连续失败多少轮后查询中转方案
+	DefaultTransferInterval    = time.Minute * 5 // 同一出发日期两次查询的最短间隔
+)
+
+// 不同车站换乘（如广州南换乘广州）时，最短换乘时间额外增加的时间
+const crossStationTransferExtra = time.Hour
+
+// TransferPlan 中转换乘方案
+type TransferPlan struct {
+	First      *common.LeftTicketInfo // 第一程，出发站到中转站
+	FirstDate  string                 // 第一程出发日期
+	Second     *common.LeftTicketInfo // 第二程，中转站到到达站
+	SecondDate string                 // 第二程出发日期，可能是第一程的第二天
+	Depart     time.Time              // 第一程出发时间
+	Arrive     time.Time              // 第二程到达时间
+	Wait       time.Duration          // 换乘等待时间
+}
+
+// SameStation 是否同站换乘
+func (p *TransferPlan) SameStation() bool {
+	return p.First.ToTelegramCode == p.Second.FromTelegramCode
+}
+
+// Duration 全程历时，包括换乘等待时间
+func (p *TransferPlan) Duration() time.Duration {
+	return p.Arrive.Sub(p.Depart)
+}
+
+// legTimes 车次在出发日期的出发时间和到达时间（北京时间）
+func legTimes(date string, info *common.LeftTicketInfo) (depart, arrive time.Time, err error) {
+	if depart, err = time.ParseInLocation("2006-01-02 15:04", date+" "+info.StartTime, clock.Shanghai); err != nil {
+		return
+	}
+
+	var hours, minutes int
+	if _, err = fmt.Sscanf(info.Duration, "%d:%d", &hours, &minutes); err != nil {
+		return
+	}
+
+	return depart, depart.Add(time.Hour*time.Duration(hours) + time.Minute*time.Duration(minutes)), nil
+}
+
+// DefaultTransferHubs 默认的中转站：站点列表中默认中转城市的站点，每个城市为一项，不包括出发站和到达站所在的城市
+func DefaultTransferHubs(stations *common.Stations, fromStations, toStations []*common.StationInfo) (hubs [][]*common.StationInfo) {
+	exclude := make(map[string]bool)
+	for _, station := range append(append([]*common.StationInfo{}, fromStations...), toStations...) {
+		exclude[station.TelegramCode] = true
+	}
+
+	for _, city := range defaultTransferHubs {
+		cityStations := stations.CityStations(city)
+
+		var skip bool
+		for _, station := range cityStations {
+			if exclude[station.TelegramCode] {
+				skip = true
+				break
+			}
+		}
+
+		if !skip && len(cityStations) > 0 {
+			hubs = append(hubs, cityStations)
+		}
+	}
+
+	return
+}
+
+// SearchTransfers 查询从出发站经中转站到达站的换乘方案，换乘等待时间在 minWait ~ maxWait 之间
+// hubs 为空时使用默认的中转城市，最多查询前 maxHubs 个中转站（0 为不限），结果按到达时间、全程历时、换乘等待时间排序
+func SearchTransfers(sess *session.Session, fromStations, toStations []*common.StationInfo, hubs [][]*common.StationInfo, maxHubs int,
+	startDate string, minWait, maxWait time.Duration, student bool) (plans []*TransferPlan, err error) {
+	if len(hubs) == 0 {
+		hubs = DefaultTransferHubs(sess.Stations, fromStations, toStations)
+	}
+
+	// 每个中转站至少查询两次余票，中转站太多时请求过于频繁
+	if maxHubs > 0 && len(hubs) > maxHubs {
+		logger.Debug("中转站数量超过限制，只查询前面的中转站", zap.Int("中转站数量", len(hubs)), zap.Int("最多查询", maxHubs))
+
+		hubs = hubs[:maxHubs]
+	}
+
+	for _, hub := range hubs {
+		var firsts []*common.LeftTicketInfo
+		if firsts, err = QueryStationsLeftTickets(sess, fromStations, hub, startDate, student); err != nil {
+			logger.Warn("查询中转第一程余票失败，略过此中转站", zap.String("中转站", common.StationNames(hub)), zap.Error(err))
+
+			continue
+		}
+
+		// 第二程按日期查询，第一程到达后可以换乘的时间可能跨天
+		seconds := make(map[string][]*common.LeftTicketInfo)
+		for _, first := range firsts {
+			depart, arrive, e := legTimes(startDate, first)
+			if e != nil {
+				logger.Debug("解析车次时间错误", zap.String("车次", first.TrainCode), zap.Error(e))
+				continue
+			}
+
+			for _, date := range transferDates(arrive, minWait, maxWait) {
+				if _, ok := seconds[date]; !ok {
+					if seconds[date], err = QueryStationsLeftTickets(sess, hub, toStations, date, student); err != nil {
+						logger.Warn("查询中转第二程余票失败", zap.String("中转站", common.StationNames(hub)), zap.String("日期", date), zap.Error(err))
+					}
+				}
+
+				for _, second := range seconds[date] {
+					if second.TrainCode == first.TrainCode {
+						continue
+					}
+
+					secondDepart, secondArrive, e := legTimes(date, second)
+					if e != nil {
+						continue
+					}
+
+					need := minWait
+					if second.FromTelegramCode != first.ToTelegramCode {
+						need += crossStationTransferExtra
+					}
+
+					wait := secondDepart.Sub(arrive)
+					if wait < need || wait > maxWait {
+						continue
+					}
+
+					plans = append(plans, &TransferPlan{
+						First:      first,
+						FirstDate:  startDate,
+						Second:     second,
+						SecondDate: date,
+						Depart:     depart,
+						Arrive:     secondArrive,
+						Wait:       wait,
+					})
+				}
+			}
+		}
+	}
+
+	sort.SliceStable(plans, func(i, j int) bool {
+		if !plans[i].Arrive.Equal(plans[j].Arrive) {
+			return plans[i].Arrive.Before(plans[j].Arrive)
+		}
+
+		if plans[i].Duration() != plans[j].Duration() {
+			return plans[i].Duration() < plans[j].Duration()
+		}
+
+		return plans[i].Wait < plans[j].Wait
+	})
+
+	return plans, nil
+}
+
+// transferDates 第一程到达后，换乘时间范围内第二程可能的出发日期
+func transferDates(arrive time.Time, minWait, maxWait time.Duration) (dates []string) {
+	from := arrive.Add(minWait)
+	to := arrive.Add(maxWait)
+	for d := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, from.Location()); !d.After(to); d = d.AddDate(0, 0, 1) {
+		dates = append(dates, d.Format("2006-01-02"))
+	}
+
+	return
+}
+
+// FilterTransferPlans 返回两程都有余票的换乘方案，seatIndices 为空时不筛选座席
+func FilterTransferPlans(plans []*TransferPlan, seatIndices []int) (matched []*TransferPlan) {
+	filter := &QueryFilter{SeatIndices: seatIndices}
+	for _, plan := range plans {
+		if filter.Match(plan.First) && filter.Match(plan.Second) {
+			matched = append(matched, plan)
+		}
+	}
+
+	return
+}
+
+// FprintTransferPlans 以表格形式输出换乘方案
+func FprintTransferPlans(w io.Writer, from, to, startDate string, plans []*TransferPlan) {
+	fmt.Fprintln(w, strings.Repeat("-", 100))
+	fmt.Fprintf(w, "出发站: %s, 到达站: %s, 出发日期: %s，共 %d 个中转方案\n", from, to, startDate, len(plans))
+
+	for i, plan := range plans {
+		station := "同站换乘"
+		if !plan.SameStation() {
+			station = "换乘到 " + plan.Second.From
+		}
+
+		fmt.Fprintf(w, "%3d. 第一程 %s %s %s %s - %s %s，%s 等待 %s，第二程 %s %s %s %s - %s %s，全程 %s\n",
+			i+1,
+			plan.First.TrainCode, plan.FirstDate, plan.First.From, plan.First.StartTime, plan.First.To, plan.First.ArriveTime,
+			station, formatDuration(plan.Wait),
+			plan.Second.TrainCode, plan.SecondDate, plan.Second.From, plan.Second.StartTime, plan.Second.To, plan.Second.ArriveTime,
+			formatDuration(plan.Duration()),
+		)
+		fmt.Fprintf(w, "     余票: %s | %s\n", seatSummary(plan.First), seatSummary(plan.Second))
+	}
+
+	fmt.Fprintln(w, strings.Repeat("-", 100))
+}
+
+// formatDuration 时长格式化为 HH:MM
+func formatDuration(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d", int(d.Hours()), int(d.Minutes())%60)
+}
+
+// seatSummary 有余票的座席，如 二等座 有，一等座 12
+func seatSummary(info *common.LeftTicketInfo) string {
+	var arr []string
+	for _, seat := range NewLeftTicketOutput(info, nil).Seats {
+		if seat.Count > 0 {
+			arr = append(arr, seat.SeatName+" "+seat.Text)
+		}
+	}
+
+	if len(arr) == 0 {
+		return info.TrainCode + " 无票"
+	}
+
+	return info.TrainCode + " " + strings.Join(arr, "，")
+}
+
+// transferIsTarget 中转方案的两程是否符合任务的车次规则，与直达车次一样排除 exclude_train_codes 并按 train_filter 筛选
+// train_codes 只对直达车次生效
+func transferIsTarget(task *worker.Task, plan *TransferPlan) bool {
+	if task.IsExcluded(plan.First) || task.IsExcluded(plan.Second) {
+		return false
+	}
+
+	switch filter := task.TrainFilter.(type) {
+	case nil:
+		return true
+	case *QueryFilter:
+		return filter.MatchTransfer(plan)
+	default:
+		return filter.Match(plan.First) && filter.Match(plan.Second)
+	}
+}
+
+// transferSeatIndex 两程都有足够余票、不在小黑屋并且票价符合限制的座席，没有时返回 -1
+func transferSeatIndex(sess *session.Session, task *worker.Task, plan *TransferPlan) int {
+	for _, seatIndex := range task.SeatIndices {
+		if blacklist.IsInBlackList(task.TaskID, plan.First.TrainCode, seatIndex) ||
+			blacklist.IsInBlackList(task.TaskID, plan.Second.TrainCode, seatIndex) {
+			continue
+		}
+
+		if plan.First.LeftTicketsCount[seatIndex] >= len(task.Passengers) &&
+			plan.Second.LeftTicketsCount[seatIndex] >= len(task.Passengers) &&
+			withinMaxPrice(sess, task, plan.First, plan.FirstDate, seatIndex) &&
+			withinMaxPrice(sess, task, plan.Second, plan.SecondDate, seatIndex) {
+			return seatIndex
+		}
+	}
+
+	return -1
+}
+
+// queryTransfer 查询中转换乘方案并按顺序尝试下单，两程都下单成功时 done 为 true
+// 第一程下单成功后尝试同一第一程的其他第二程，都失败时通知用户处理已下单的第一程
+func queryTransfer(sess *session.Session, task *worker.Task, startDate string) (done bool, err error) {
+	var plans []*TransferPlan
+	if plans, err = SearchTransfers(sess, task.FromStations, task.ToStations, task.Transfer.Hubs, task.Transfer.MaxHubs, startDate,
+		task.Transfer.MinWait, task.Transfer.MaxWait, task.Student); err != nil {
+		return
+	}
+
+	var targets []*TransferPlan
+	for _, plan := range plans {
+		if transferIsTarget(task, plan) {
+			targets = append(targets, plan)
+		}
+	}
+	plans = targets
+
+	// 仅查询
+	if task.QueryOnly {
+		FprintTransferPlans(os.Stdout, task.From, task.To, startDate, FilterTransferPlans(plans, task.SeatIndices))
+
+		return
+	}
+
+	for _, plan := range plans {
+		if !plan.First.CanOrder || !plan.Second.CanOrder {
+			continue
+		}
+
+		seatIndex := transferSeatIndex(sess, task, plan)
+		if seatIndex < 0 {
+			continue
+		}
+
+		logger.Info("发现中转方案两程余票足够，准备尝试下单...",
+			zap.String("第一程", plan.First.TrainCode+" "+plan.First.From+" - "+plan.First.To),
+			zap.String("第二程", plan.Second.TrainCode+" "+plan.Second.From+" - "+plan.Second.To),
+			zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+			zap.Duration("换乘等待", plan.Wait),
+			zap.Array("乘客", task.Passengers),
+		)
+
+		if err = order.DoOrder(sess, task, plan.First, plan.FirstDate, plan.First.TrainCode, seatIndex,
+			passengerTickets(task, len(task.Passengers), seatIndex)); err != nil {
+			logger.Warn("中转第一程下单失败，将此车次加入小黑屋",
+				zap.Int64("任务 ID", task.TaskID),
+				zap.String("车次", plan.First.TrainCode),
+				zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+			)
+
+			blacklist.AddToBlackList(task.TaskID, plan.First.TrainCode, seatIndex, task.BlackTime)
+			continue
+		}
+
+		if err = orderTransferSecond(sess, task, plan, plans, seatIndex); err != nil {
+			notifier.Broadcast(&sess.Cfg.Notifier, fmt.Sprintf("GOGO12306 中转换乘的第一程 %s %s %s 至 %s 已下单成功，但第二程 %s %s %s 至 %s 下单失败，请尽快登陆 12306 网站或使用 12306 APP 取消第一程订单，或自行购买 %s 至 %s 的车票",
+				plan.FirstDate, plan.First.TrainCode, plan.First.From, plan.First.To,
+				plan.SecondDate, plan.Second.TrainCode, plan.Second.From, plan.Second.To,
+				plan.Second.From, plan.Second.To,
+			))
+		}
+
+		// 第一程已下单，无论第二程是否成功都结束任务，避免重复购买第一程
+		return true, err
+	}
+
+	return
+}
+
+// orderTransferSecond 第一程下单成功后购买第二程，先尝试方案中的第二程，失败时再尝试同一第一程的其他第二程
+func orderTransferSecond(sess *session.Session, task *worker.Task, plan *TransferPlan, plans []*TransferPlan, firstSeatIndex int) (err error) {
+	candidates := []*TransferPlan{plan}
+	for _, p := range plans {
+		if p != plan && p.First == plan.First && p.Second.CanOrder {
+			candidates = append(candidates, p)
+		}
+	}
+
+	for _, p := range candidates {
+		seatIndex := firstSeatIndex
+		if p.Second.LeftTicketsCount[seatIndex] < len(task.Passengers) ||
+			!withinMaxPrice(sess, task, p.Second, p.SecondDate, seatIndex) {
+			if seatIndex = transferSeatIndex(sess, task, p); seatIndex < 0 {
+				continue
+			}
+		}
+
+		if err = order.DoOrder(sess, task, p.Second, p.SecondDate, p.Second.TrainCode, seatIndex,
+			passengerTickets(task, len(task.Passengers), seatIndex)); err != nil {
+			logger.Warn("中转第二程下单失败，尝试其他第二程车次",
+				zap.String("车次", p.Second.TrainCode),
+				zap.String("座席类型", common.SeatIndexToSeatName(seatIndex)),
+				zap.Error(err),
+			)
+
+			continue
+		}
+
+		logger.Info("中转换乘两程都已下单成功",
+			zap.String("第一程", plan.First.TrainCode+" "+plan.First.From+" - "+plan.First.To),
+			zap.String("第二程", p.Second.TrainCode+" "+p.Second.From+" - "+p.Second.To),
+		)
+
+		return nil
+	}
+
+	return errors.New("transfer second leg order failure")
+}
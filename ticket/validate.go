@@ -0,0 +1,179 @@
+package ticket
+
+import (
+	"fmt"
+	"gogo12306/common"
+	"gogo12306/config"
+	"gogo12306/session"
+	"sort"
+	"strings"
+)
+
+// 候补票截止兑换时间的范围，单位: 分钟
+const (
+	CandidateDeadlineMin = 120
+	CandidateDeadlineMax = 1440
+)
+
+// 可以选座的座席类型及可选的座位号
+var chooseSeatLetters = map[string]string{
+	"商务座": "ACF",
+	"特等座": "ACF",
+	"一等座": "ACDF",
+	"二等座": "ABCDF",
+}
+
+// ValidateTask 检查任务配置，返回所有发现的问题，path 为任务在配置中的 JSON 路径（如 tasks[0]）
+// 站点列表为空时不检查站名，联系人列表为空时不检查乘车人
+func ValidateTask(sess *session.Session, path string, taskCfg *config.TaskConfig) (problems config.Problems) {
+	// 车票类型
+	ticketType, err := taskTicketType(taskCfg.TicketType)
+	if err != nil {
+		problems.Add(path+".ticket_type", "%s", err.Error())
+	}
+
+	// 每个乘客的车票类型，配置错误时为 -1，不再检查该乘客能否购买
+	n := len(taskCfg.TicketTypes)
+	if len(taskCfg.Passengers) > n {
+		n = len(taskCfg.Passengers)
+	}
+	if len(taskCfg.UUIDs) > n {
+		n = len(taskCfg.UUIDs)
+	}
+
+	ticketTypes := make([]int, n)
+	for i := range ticketTypes {
+		if ticketTypes[i], err = ticketTypeAt(taskCfg.TicketTypes, i); err != nil {
+			problems.Add(fmt.Sprintf("%s.ticket_types[%d]", path, i), "%s", err.Error())
+			ticketTypes[i] = -1
+		}
+	}
+
+	// 站点
+	if sess.Stations.Len() > 0 {
+		if taskCfg.From != "" {
+			if _, err := sess.Stations.Resolve(taskCfg.From); err != nil {
+				problems.Add(path+".from", "出发站错误: %s", err.Error())
+			}
+		}
+
+		if taskCfg.To != "" {
+			if _, err := sess.Stations.Resolve(taskCfg.To); err != nil {
+				problems.Add(path+".to", "到达站错误: %s", err.Error())
+			}
+		}
+
+		if taskCfg.TrainFilter != nil {
+			for i, station := range taskCfg.TrainFilter.FromStations {
+				if _, err := sess.Stations.Resolve(station); err != nil {
+					problems.Add(fmt.Sprintf("%s.train_filter.from_stations[%d]", path, i), "出发站错误: %s", err.Error())
+				}
+			}
+
+			for i, station := range taskCfg.TrainFilter.ToStations {
+				if _, err := sess.Stations.Resolve(station); err != nil {
+					problems.Add(fmt.Sprintf("%s.train_filter.to_stations[%d]", path, i), "到达站错误: %s", err.Error())
+				}
+			}
+		}
+
+		for i, hub := range taskCfg.TransferHubs {
+			if _, err := sess.Stations.Resolve(hub); err != nil {
+				problems.Add(fmt.Sprintf("%s.transfer_hubs[%d]", path, i), "中转站错误: %s", err.Error())
+			}
+		}
+	}
+
+	// 车次筛选规则
+	if taskCfg.TrainFilter != nil {
+		if _, err := NewQueryFilter(taskCfg.TrainFilter.TrainTypes, taskCfg.TrainFilter.Depart, taskCfg.TrainFilter.Arrive, nil); err != nil {
+			problems.Add(path+".train_filter", "%s", err.Error())
+		}
+	}
+
+	// 座席
+	canChooseSeats := ""
+	for i, seatName := range taskCfg.Seats {
+		if _, _, err := seatNamesToSeatIndices([]string{seatName}); err != nil {
+			problems.Add(fmt.Sprintf("%s.seats[%d]", path, i), "未知的座席类型 %q", seatName)
+		}
+
+		if seatName == "全部" {
+			canChooseSeats = "ABCDF"
+		} else if letters, ok := chooseSeatLetters[seatName]; ok {
+			canChooseSeats += letters
+		} else if strings.HasPrefix(seatName, "二等") {
+			canChooseSeats += chooseSeatLetters["二等座"]
+		}
+	}
+
+	// 最高票价
+	var maxPriceSeats []string
+	for seatName := range taskCfg.MaxPrices {
+		maxPriceSeats = append(maxPriceSeats, seatName)
+	}
+	sort.Strings(maxPriceSeats)
+
+	for _, seatName := range maxPriceSeats {
+		if _, _, err := seatNamesToSeatIndices([]string{seatName}); err != nil || seatName == "全部" {
+			problems.Add(path+".max_prices", "未知的座席类型 %q", seatName)
+		}
+	}
+
+	// 选座
+	for i, seat := range taskCfg.ChooseSeats {
+		seatPath := fmt.Sprintf("%s.choose_seats[%d]", path, i)
+		if seat == "" {
+			continue
+		}
+
+		if len(seat) != 2 || (seat[0] != '1' && seat[0] != '2') || !strings.ContainsRune("ABCDF", rune(seat[1])) {
+			problems.Add(seatPath, "选座 %q 格式错误，应为排号 1/2 加座位号 A/B/C/D/F，如 1A", seat)
+		} else if canChooseSeats == "" {
+			problems.Add(seatPath, "座席类型 %v 都不能选座，只有商务座、特等座、一等座、二等座可以选座", taskCfg.Seats)
+		} else if !strings.ContainsRune(canChooseSeats, rune(seat[1])) {
+			problems.Add(seatPath, "座席类型 %v 没有 %c 座", taskCfg.Seats, seat[1])
+		}
+	}
+
+	// 选铺
+	for i, bed := range taskCfg.SeatDetailType {
+		if bed != "0" && bed != "1" {
+			problems.Add(fmt.Sprintf("%s.seat_detail_type[%d]", path, i), "选铺只能是 0 或 1")
+		}
+	}
+
+	// 候补
+	if taskCfg.AllowCandidate &&
+		(taskCfg.CandidateDeadline < CandidateDeadlineMin || taskCfg.CandidateDeadline > CandidateDeadlineMax) {
+		problems.Add(path+".candidate_deadline", "候补截止兑换时间 %d 分钟超出范围 %d ~ %d",
+			taskCfg.CandidateDeadline, CandidateDeadlineMin, CandidateDeadlineMax)
+	}
+
+	// 乘车人，只查询的任务不需要
+	if !taskCfg.QueryOnly && sess.PassengerCount() > 0 {
+		for i, name := range taskCfg.Passengers {
+			passengerPath := fmt.Sprintf("%s.passengers[%d]", path, i)
+			if passenger := sess.GetPassenger(strings.TrimSpace(name)); passenger == nil {
+				problems.Add(passengerPath, "乘车人 %q 不在账号 %q 的联系人列表中", name, sess.Name)
+			} else if ticketTypes[i] >= 0 {
+				if _, err := passengerTicketType(ticketType == common.TicketTypeStudent, ticketTypes[i], passenger); err != nil {
+					problems.Add(passengerPath, "%s", err.Error())
+				}
+			}
+		}
+
+		for i, uuid := range taskCfg.UUIDs {
+			uuidPath := fmt.Sprintf("%s.uuids[%d]", path, i)
+			if passenger := sess.GetPassengerByUUID(strings.TrimSpace(uuid)); passenger == nil {
+				problems.Add(uuidPath, "UUID %q 不在账号 %q 的联系人列表中", uuid, sess.Name)
+			} else if ticketTypes[i] >= 0 {
+				if _, err := passengerTicketType(ticketType == common.TicketTypeStudent, ticketTypes[i], passenger); err != nil {
+					problems.Add(uuidPath, "%s", err.Error())
+				}
+			}
+		}
+	}
+
+	return
+}
@@ -2,16 +2,77 @@ package worker
 
 import (
 	"gogo12306/common"
-	"net/http/cookiejar"
+	"strings"
+	"sync"
 	"time"
 )
 
-type TaskCB func(jar *cookiejar.Jar, task *Task) (err error)
+type TaskCB func(task *Task) (err error)
+
+// 行程类型，下单时的 tour_flag
+const (
+	TourFlagSingle = "dc" // 单程
+	TourFlagGo     = "wc" // 往返的去程
+	TourFlagBack   = "fc" // 往返的返程
+)
+
+// BackTrip 往返票的返程
+type BackTrip struct {
+	From string
+	To   string
+
+	FromStations []*common.StationInfo
+	ToStations   []*common.StationInfo
+
+	StartDates []string
+	TrainCodes []string
+}
+
+// TrainMatcher 车次筛选规则
+type TrainMatcher interface {
+	Match(info *common.LeftTicketInfo) bool
+}
+
+// Transfer 中转换乘的设置
+type Transfer struct {
+	Hubs    [][]*common.StationInfo // 中转站，每项为一个中转城市或站点列表，为空时使用默认的中转城市
+	MaxHubs int                     // 最多查询的中转站数量
+	MinWait time.Duration           // 最短换乘时间
+	MaxWait time.Duration           // 最长换乘时间
+
+	AfterCycles int           // 直达车次连续多少轮没有下单成功后才查询中转方案
+	Interval    time.Duration // 同一出发日期两次查询中转方案的最短间隔
+
+	failures   map[string]int       // 直达车次连续没有下单成功的轮数，Key: 出发日期
+	lastSearch map[string]time.Time // 上次查询中转方案的时间，Key: 出发日期
+}
+
+// DirectFailed 记录直达车次在出发日期没有下单成功，返回是否需要查询中转方案
+// 中转方案每个中转站都要查询两程余票，请求较多，因此只在直达车次连续失败 AfterCycles 轮后、每隔 Interval 查询一次
+func (t *Transfer) DirectFailed(startDate string, now time.Time) bool {
+	if t.failures == nil {
+		t.failures = make(map[string]int)
+		t.lastSearch = make(map[string]time.Time)
+	}
+
+	t.failures[startDate]++
+	if t.failures[startDate] < t.AfterCycles {
+		return false
+	}
+
+	if last, ok := t.lastSearch[startDate]; ok && now.Sub(last) < t.Interval {
+		return false
+	}
+
+	t.lastSearch[startDate] = now
+	return true
+}
 
 type Task struct {
-	TaskID    int64
-	QueryOnly bool
-	Done      chan struct{}
+	TaskID      int64
+	QueryOnly   bool
+	QueryFormat string // 仅查询时余票的输出格式
+	Done        chan struct{}
 
 	OrderType int
 	BlackTime int
@@ -19,16 +80,32 @@ type Task struct {
 	AllowCandidate    bool
 	CandidateDeadline int
 
+	Student bool // 是否购买学生票，查询和下单使用学生票的 purpose_codes，开售时间按学生票预售天数计算
+
+	TourFlag string    // 行程类型：dc 单程，wc 往返的去程，fc 往返的返程
+	Back     *BackTrip // 往返票的返程，去程下单成功后继续抢返程，单程时为 nil
+
+	Transfer *Transfer // 中转换乘，不为 nil 时查询经过中转站的两程车票并一起下单
+
+	LongerSegment         bool          // 买长乘短，指定车次的原区间没有余票时购买同一车次的更长区间
+	LongerSegmentInterval time.Duration // 同一车次两次查询更长区间的最短间隔
+
+	longerSegmentAt map[string]time.Time // 上次查询更长区间的时间，Key: 车次_出发日期
+
 	From string
 	To   string
 
-	FromTelegramCode string
-	ToTelegramCode   string
+	FromStations []*common.StationInfo // 所有出发站
+	ToStations   []*common.StationInfo // 所有到达站
 
 	StartDates []string    // 出发日期
 	SaleTimes  []time.Time // 开售时间
 
-	TrainCodes []string
+	UpdateSaleTimes func(task *Task) // 重新计算开售时间，预售天数可能在登录后才能确定
+
+	TrainCodes        []string
+	TrainFilter       TrainMatcher // 车次筛选规则，符合规则的车次与 TrainCodes 一样尝试下单，为 nil 时只购买 TrainCodes
+	ExcludeTrainCodes []string     // 不购买的车次，优先于 TrainCodes 和 TrainFilter
 
 	Seats          []string
 	SeatTypes      []int
@@ -37,9 +114,115 @@ type Task struct {
 	SeatDetailType []string
 	AllowNoSeat    bool
 
+	MaxPrice      float64         // 最高票价，0 为不限
+	SeatMaxPrices map[int]float64 // 各座席的最高票价，Key: 座席索引，优先于 MaxPrice
+
+	MaxUpgradePrice float64 // 比 SeatIndices 中排在前面的座席贵出的最高差价，0 为不限
+
 	Passengers  common.PassengerInfos
+	TicketTypes []int // 每个乘客的车票类型，与 Passengers 一一对应
 	AllowPartly bool
 
 	NextQueryTime time.Time
 	CB            TaskCB
+
+	stopMu   sync.Mutex
+	stopOnce sync.Once
+	stop     chan struct{}
+}
+
+func (t *Task) stopC() chan struct{} {
+	t.stopMu.Lock()
+	defer t.stopMu.Unlock()
+
+	if t.stop == nil {
+		t.stop = make(chan struct{})
+	}
+
+	return t.stop
+}
+
+// Stop 停止任务，如重新加载配置时删除了该任务
+func (t *Task) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.stopC())
+	})
+}
+
+// IsExcluded 车次是否在 ExcludeTrainCodes 中
+func (t *Task) IsExcluded(info *common.LeftTicketInfo) bool {
+	trainCode := strings.ToUpper(info.TrainCode)
+	for _, code := range t.ExcludeTrainCodes {
+		if code == trainCode {
+			return true
+		}
+	}
+
+	return false
+}
+
+// IsTarget 车次是否为待购买的车次：不在 ExcludeTrainCodes 中，并且在 TrainCodes 中或符合 TrainFilter
+func (t *Task) IsTarget(info *common.LeftTicketInfo) bool {
+	if t.IsExcluded(info) {
+		return false
+	}
+
+	trainCode := strings.ToUpper(info.TrainCode)
+	for _, code := range t.TrainCodes {
+		if code == trainCode {
+			return true
+		}
+	}
+
+	return t.TrainFilter != nil && t.TrainFilter.Match(info)
+}
+
+// MaxPriceOf 座席的最高票价，0 为不限
+func (t *Task) MaxPriceOf(seatIndex int) float64 {
+	if price, ok := t.SeatMaxPrices[seatIndex]; ok {
+		return price
+	}
+
+	return t.MaxPrice
+}
+
+// LongerSegmentDue 是否可以查询车次的更长区间，可以时记录查询时间
+// 每个车次最多查询 6 个区间的余票和票价，因此同一车次每隔 LongerSegmentInterval 才查询一次
+func (t *Task) LongerSegmentDue(trainCode, startDate string, now time.Time) bool {
+	if t.longerSegmentAt == nil {
+		t.longerSegmentAt = make(map[string]time.Time)
+	}
+
+	key := trainCode + "_" + startDate
+	if last, ok := t.longerSegmentAt[key]; ok && now.Sub(last) < t.LongerSegmentInterval {
+		return false
+	}
+
+	t.longerSegmentAt[key] = now
+	return true
+}
+
+// SwitchToBack 往返票去程下单成功后切换到返程，之后的查询和下单都针对返程，没有返程时返回 false
+func (t *Task) SwitchToBack() bool {
+	if t.Back == nil {
+		return false
+	}
+
+	back := t.Back
+	t.Back = nil
+	t.TourFlag = TourFlagBack
+
+	t.From, t.To = back.From, back.To
+	t.FromStations, t.ToStations = back.FromStations, back.ToStations
+	t.StartDates = back.StartDates
+	t.TrainCodes = back.TrainCodes
+	t.TrainFilter = nil // 筛选规则针对去程的站点和时间，返程只购买 back_train_codes
+	t.SaleTimes = nil
+	t.NextQueryTime = time.Now()
+
+	if t.UpdateSaleTimes != nil {
+		t.UpdateSaleTimes(t)
+	}
+
+	return true
 }
@@ -1,9 +1,9 @@
 package worker
 
 import (
+	"gogo12306/clock"
 	"gogo12306/httpcli"
 	"gogo12306/logger"
-	"net/http/cookiejar"
 	"time"
 
 	"go.uber.org/zap"
@@ -43,16 +43,33 @@ func Do(item *Item) {
 	itemGoRoutines++
 }
 
-func DoTask(jar *cookiejar.Jar, task *Task) {
-	go func(j *cookiejar.Jar, t *Task) {
+func DoTask(task *Task) {
+	go func(t *Task) {
 		tk := time.NewTicker(time.Second)
+		defer tk.Stop()
+
+		stop := t.stopC()
 		for {
 			select {
 			case <-t.Done:
 				return
 
+			case <-stop:
+				logger.Info("任务已停止",
+					zap.String("出发站", t.From),
+					zap.String("到达站", t.To),
+					zap.Strings("出发日期", t.StartDates),
+				)
+
+				return
+
 			case <-tk.C:
-				now := time.Now()
+				// 使用校正后的服务器时间，保证在开售时刻准时开始
+				now := clock.Now()
+
+				if t.UpdateSaleTimes != nil {
+					t.UpdateSaleTimes(t)
+				}
 
 				if now.Before(t.NextQueryTime) { // 未到查询时间
 					// 重新设置下次查询时间
@@ -113,11 +130,11 @@ func DoTask(jar *cookiejar.Jar, task *Task) {
 					zap.Strings("出发日期", task.StartDates),
 				)
 
-				// go t.CB(j, t)
-				t.CB(j, t)
+				// go t.CB(t)
+				t.CB(t)
 
 				tk.Reset(INTERVAL)
 			}
 		}
-	}(jar, task)
+	}(task)
 }